@@ -0,0 +1,586 @@
+// Command sessionsctl inspects and maintains the sessions database of a
+// DBSessionStore.
+//
+// Usage:
+//
+//	sessionsctl [-db sessions.db] [-driver sqlite] [-tenant name] [-json] [-show-ids] <command> [arguments]
+//
+// Commands:
+//
+//	list [-user id] [-expired] [-limit n]  list sessions
+//	inspect <ref>                         show one session
+//	revoke <ref>... | -user id            revoke sessions
+//	purge                                 delete expired sessions
+//	counts                                count sessions and users
+//	ages                                  histogram of the age of active sessions
+//	export [-skip-expired]                write sessions to standard output
+//	import [-skip-expired] [-rehash-ids] <file>
+//	                                      read sessions written by export
+//	migrate                               apply pending schema migrations
+//
+// Sessions are printed with their session.RedactSessionID ref instead of
+// their ID, which is a credential; -show-ids prints IDs as well. inspect and
+// revoke accept refs and IDs.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ManuL3/sessions/session"
+)
+
+// pageSize is the number of sessions read from the database at a time.
+const pageSize = 500
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "sessionsctl:", err)
+		os.Exit(1)
+	}
+}
+
+// ctl holds the database and output settings shared by all commands.
+type ctl struct {
+	db      *session.DBSessionStore
+	store   session.SessionStore
+	tenant  string
+	json    bool
+	showIDs bool
+	out     io.Writer
+}
+
+func run(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("sessionsctl", flag.ContinueOnError)
+	flags.SetOutput(out)
+	dsn := flags.String("db", "sessions.db", "database to open")
+	driver := flags.String("driver", "sqlite", "database/sql driver name")
+	tenant := flags.String("tenant", "", "restrict commands to the sessions of one tenant")
+	asJSON := flags.Bool("json", false, "print JSON instead of tables")
+	showIDs := flags.Bool("show-ids", false, "print session IDs, which are credentials, next to their refs")
+	flags.Usage = func() {
+		fmt.Fprintln(out, "usage: sessionsctl [flags] list|inspect|revoke|purge|counts|ages|export|import|migrate [arguments]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("no command given")
+	}
+
+	db, err := session.OpenDBSessionStore(*dsn, *driver)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	c := &ctl{db: db, store: db, tenant: *tenant, json: *asJSON, showIDs: *showIDs, out: out}
+	if *tenant != "" {
+		c.store = db.ForTenant(*tenant)
+	}
+
+	command, args := flags.Arg(0), flags.Args()[1:]
+	if command == "migrate" {
+		return c.migrate(args)
+	}
+
+	pending, err := db.PendingMigrations()
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("database schema is %d migrations behind, run sessionsctl migrate first", pending)
+	}
+
+	switch command {
+	case "list":
+		return c.list(args)
+	case "inspect":
+		return c.inspect(args)
+	case "revoke":
+		return c.revoke(args)
+	case "purge":
+		return c.purge(args)
+	case "counts":
+		return c.counts(args)
+	case "ages":
+		return c.ages(args)
+	case "export":
+		return c.exportSessions(args)
+	case "import":
+		return c.importSessions(args)
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+}
+
+// sessionView is the printed form of a session. Values are left out, since
+// they hold secrets such as the CSRF key. Sessions are referenced by
+// session.RedactSessionID; the ID is only set with -show-ids.
+type sessionView struct {
+	Ref            string    `json:"ref"`
+	ID             string    `json:"id,omitempty"`
+	UserID         string    `json:"user_id"`
+	Tenant         string    `json:"tenant,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	LastSeenAt     time.Time `json:"last_seen_at"`
+	Expired        bool      `json:"expired"`
+	CreatedIP      string    `json:"created_ip,omitempty"`
+	LastIP         string    `json:"last_ip,omitempty"`
+	UserAgent      string    `json:"user_agent,omitempty"`
+	Device         string    `json:"device,omitempty"`
+	AuthLevel      int       `json:"auth_level"`
+	Roles          []string  `json:"roles,omitempty"`
+	Scopes         []string  `json:"scopes,omitempty"`
+	ImpersonatorID string    `json:"impersonator_id,omitempty"`
+}
+
+func (c *ctl) newSessionView(s *session.SessionData) sessionView {
+	v := sessionView{
+		Ref:            session.RedactSessionID(s.ID),
+		UserID:         s.UserID,
+		Tenant:         s.Tenant,
+		CreatedAt:      s.CreatedAt,
+		ExpiresAt:      s.ExpiresAt,
+		LastSeenAt:     s.LastSeenAt,
+		Expired:        s.ExpiresAt.Before(time.Now()),
+		CreatedIP:      s.CreatedIP,
+		LastIP:         s.LastIP,
+		UserAgent:      s.UserAgent,
+		Device:         s.Device,
+		AuthLevel:      int(s.AuthLevel),
+		Roles:          s.Roles,
+		Scopes:         s.Scopes,
+		ImpersonatorID: s.ImpersonatorID,
+	}
+	if c.showIDs {
+		v.ID = s.ID
+	}
+	return v
+}
+
+// refLength is the length of the refs returned by session.RedactSessionID,
+// which never equals the length of a session ID.
+var refLength = len(session.RedactSessionID(""))
+
+// findSession returns the session referenced by arg, a ref or a session ID,
+// whether or not it has expired. Refs are one-way, so they are looked up by
+// reading through the sessions.
+func (c *ctl) findSession(arg string) (*session.SessionData, error) {
+	if len(arg) == refLength {
+		var found *session.SessionData
+		err := c.eachSession(session.SessionQuery{IncludeExpired: true}, func(s *session.SessionData) {
+			if session.RedactSessionID(s.ID) == arg {
+				found = s
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		if found == nil {
+			return nil, fmt.Errorf("session %s not found", arg)
+		}
+		return found, nil
+	}
+
+	// GetSession deletes expired sessions, while inspecting must not change
+	// the database.
+	lookup, ok := c.store.(interface {
+		LookupSession(sessionID string) (*session.SessionData, error)
+	})
+	if !ok {
+		return nil, errors.New("store cannot look up sessions")
+	}
+	s, err := lookup.LookupSession(arg)
+	if errors.Is(err, session.ErrSessionNotFound) {
+		return nil, fmt.Errorf("session %s not found", session.RedactSessionID(arg))
+	}
+	return s, err
+}
+
+// eachSession calls fn for every session matching query, reading them from
+// the database one page at a time.
+func (c *ctl) eachSession(query session.SessionQuery, fn func(*session.SessionData)) error {
+	lister, ok := c.store.(session.SessionLister)
+	if !ok {
+		return errors.New("store cannot list sessions")
+	}
+
+	limit := query.Limit
+	for {
+		query.Limit = pageSize
+		if limit > 0 && limit < pageSize {
+			query.Limit = limit
+		}
+
+		page, err := lister.ListSessions(query)
+		if err != nil {
+			return err
+		}
+		for _, s := range page {
+			fn(s)
+		}
+
+		if limit > 0 {
+			limit -= len(page)
+			if limit == 0 {
+				return nil
+			}
+		}
+		if len(page) < query.Limit {
+			return nil
+		}
+		query.After = page[len(page)-1].ID
+	}
+}
+
+func (c *ctl) list(args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	flags.SetOutput(c.out)
+	user := flags.String("user", "", "only list the sessions of this user")
+	expired := flags.Bool("expired", false, "include expired sessions")
+	limit := flags.Int("limit", 0, "list at most this many sessions")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	views := []sessionView{}
+	err := c.eachSession(session.SessionQuery{UserID: *user, IncludeExpired: *expired, Limit: *limit}, func(s *session.SessionData) {
+		views = append(views, c.newSessionView(s))
+	})
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.printJSON(views)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	header := "REF\tUSER\tTENANT\tCREATED\tEXPIRES\tLAST SEEN\tLAST IP\tDEVICE"
+	if c.showIDs {
+		header += "\tID"
+	}
+	fmt.Fprintln(w, header)
+	for _, v := range views {
+		expires := formatTime(v.ExpiresAt)
+		if v.Expired {
+			expires += " (expired)"
+		}
+		row := strings.Join([]string{v.Ref, v.UserID, v.Tenant, formatTime(v.CreatedAt), expires, formatTime(v.LastSeenAt), v.LastIP, v.Device}, "\t")
+		if c.showIDs {
+			row += "\t" + v.ID
+		}
+		fmt.Fprintln(w, row)
+	}
+	return w.Flush()
+}
+
+func (c *ctl) inspect(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: sessionsctl inspect <ref>")
+	}
+
+	s, err := c.findSession(args[0])
+	if err != nil {
+		return err
+	}
+
+	v := c.newSessionView(s)
+	if c.json {
+		return c.printJSON(v)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Ref\t%s\n", v.Ref)
+	if c.showIDs {
+		fmt.Fprintf(w, "ID\t%s\n", v.ID)
+	}
+	fmt.Fprintf(w, "User\t%s\n", v.UserID)
+	fmt.Fprintf(w, "Tenant\t%s\n", v.Tenant)
+	fmt.Fprintf(w, "Created\t%s from %s\n", formatTime(v.CreatedAt), v.CreatedIP)
+	fmt.Fprintf(w, "Expires\t%s\n", formatTime(v.ExpiresAt))
+	fmt.Fprintf(w, "Expired\t%t\n", v.Expired)
+	fmt.Fprintf(w, "Last seen\t%s from %s\n", formatTime(v.LastSeenAt), v.LastIP)
+	fmt.Fprintf(w, "User agent\t%s\n", v.UserAgent)
+	fmt.Fprintf(w, "Device\t%s\n", v.Device)
+	fmt.Fprintf(w, "Auth level\t%d\n", v.AuthLevel)
+	fmt.Fprintf(w, "Roles\t%s\n", strings.Join(v.Roles, ", "))
+	fmt.Fprintf(w, "Scopes\t%s\n", strings.Join(v.Scopes, ", "))
+	fmt.Fprintf(w, "Impersonator\t%s\n", v.ImpersonatorID)
+	return w.Flush()
+}
+
+func (c *ctl) revoke(args []string) error {
+	flags := flag.NewFlagSet("revoke", flag.ContinueOnError)
+	flags.SetOutput(c.out)
+	user := flags.String("user", "", "revoke all sessions of this user")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var ids []string
+	tenants := []string{c.tenant}
+	switch {
+	case *user != "" && flags.NArg() > 0:
+		return errors.New("pass either sessions or -user, not both")
+	case *user != "":
+		err := c.eachSession(session.SessionQuery{UserID: *user, IncludeExpired: true}, func(s *session.SessionData) {
+			ids = append(ids, s.ID)
+			if !slices.Contains(tenants, s.Tenant) {
+				tenants = append(tenants, s.Tenant)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	case flags.NArg() == 0:
+		return errors.New("usage: sessionsctl revoke <ref>... | -user <id>")
+	default:
+		for _, arg := range flags.Args() {
+			s, err := c.findSession(arg)
+			if err != nil {
+				return err
+			}
+			ids = append(ids, s.ID)
+		}
+	}
+
+	for _, id := range ids {
+		if err := c.store.DeleteSession(id); err != nil {
+			return err
+		}
+	}
+
+	// Without its remember-me tokens, the user cannot be logged back in by a
+	// remember-me cookie.
+	if *user != "" {
+		for _, tenant := range tenants {
+			if err := c.db.DeleteUserRememberTokens(tenant, *user); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.json {
+		return c.printJSON(map[string]int{"revoked": len(ids)})
+	}
+	_, err := fmt.Fprintf(c.out, "revoked %d sessions\n", len(ids))
+	return err
+}
+
+func (c *ctl) purge(args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: sessionsctl purge")
+	}
+
+	purged, err := c.store.(session.ExpiredSessionPurger).PurgeExpiredSessions()
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.printJSON(map[string]int{"deleted": len(purged)})
+	}
+	_, err = fmt.Fprintf(c.out, "deleted %d expired sessions\n", len(purged))
+	return err
+}
+
+// sessionCounts is the output of the counts command.
+type sessionCounts struct {
+	Total   int `json:"total"`
+	Active  int `json:"active"`
+	Expired int `json:"expired"`
+	// Users is the number of users with at least one active session.
+	Users int `json:"users"`
+}
+
+func (c *ctl) counts(args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: sessionsctl counts")
+	}
+
+	var counts sessionCounts
+	users := make(map[string]bool)
+	now := time.Now()
+	err := c.eachSession(session.SessionQuery{IncludeExpired: true}, func(s *session.SessionData) {
+		counts.Total++
+		if s.ExpiresAt.Before(now) {
+			counts.Expired++
+			return
+		}
+		counts.Active++
+		users[s.UserID] = true
+	})
+	if err != nil {
+		return err
+	}
+	counts.Users = len(users)
+
+	if c.json {
+		return c.printJSON(counts)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Total\t%d\n", counts.Total)
+	fmt.Fprintf(w, "Active\t%d\n", counts.Active)
+	fmt.Fprintf(w, "Expired\t%d\n", counts.Expired)
+	fmt.Fprintf(w, "Users\t%d\n", counts.Users)
+	return w.Flush()
+}
+
+// ageBucket is a bar of the ages histogram: active sessions created less
+// than Below ago and not counted by an earlier bucket.
+type ageBucket struct {
+	Label    string        `json:"age"`
+	Below    time.Duration `json:"-"`
+	Sessions int           `json:"sessions"`
+}
+
+// histogramWidth is the length of the longest bar printed by ages.
+const histogramWidth = 40
+
+func newAgeBuckets() []ageBucket {
+	const day = 24 * time.Hour
+	return []ageBucket{
+		{Label: "< 1h", Below: time.Hour},
+		{Label: "1h - 1d", Below: day},
+		{Label: "1d - 7d", Below: 7 * day},
+		{Label: "7d - 30d", Below: 30 * day},
+		{Label: ">= 30d", Below: 1<<63 - 1},
+	}
+}
+
+func (c *ctl) ages(args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: sessionsctl ages")
+	}
+
+	buckets := newAgeBuckets()
+	now := time.Now()
+	err := c.eachSession(session.SessionQuery{}, func(s *session.SessionData) {
+		age := now.Sub(s.CreatedAt)
+		for i := range buckets {
+			if age < buckets[i].Below {
+				buckets[i].Sessions++
+				return
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.printJSON(buckets)
+	}
+
+	largest := 0
+	for _, b := range buckets {
+		largest = max(largest, b.Sessions)
+	}
+
+	w := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "AGE\tSESSIONS\t")
+	for _, b := range buckets {
+		bar := 0
+		if largest > 0 {
+			bar = (b.Sessions*histogramWidth + largest - 1) / largest
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\n", b.Label, b.Sessions, strings.Repeat("#", bar))
+	}
+	return w.Flush()
+}
+
+func (c *ctl) exportSessions(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(c.out)
+	var opts session.ExportOptions
+	flags.BoolVar(&opts.SkipExpired, "skip-expired", false, "leave out expired sessions")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	_, err := session.Export(c.out, c.store, opts)
+	return err
+}
+
+func (c *ctl) importSessions(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(c.out)
+	var opts session.ImportOptions
+	flags.BoolVar(&opts.SkipExpired, "skip-expired", false, "leave out expired sessions")
+	flags.BoolVar(&opts.RehashIDs, "rehash-ids", false, "give every session a new ID, so the IDs in the file stop working")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: sessionsctl import [-skip-expired] [-rehash-ids] <file>")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	imported, err := session.Import(file, c.store, opts)
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.printJSON(map[string]int{"imported": imported})
+	}
+	_, err = fmt.Fprintf(c.out, "imported %d sessions\n", imported)
+	return err
+}
+
+func (c *ctl) migrate(args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: sessionsctl migrate")
+	}
+
+	pending, err := c.db.PendingMigrations()
+	if err != nil {
+		return err
+	}
+	if err := c.db.Migrate(); err != nil {
+		return err
+	}
+	version, err := c.db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	if c.json {
+		return c.printJSON(map[string]int{"applied": pending, "version": version})
+	}
+	_, err = fmt.Fprintf(c.out, "applied %d migrations, schema version %d\n", pending, version)
+	return err
+}
+
+func (c *ctl) printJSON(v any) error {
+	encoder := json.NewEncoder(c.out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// formatTime prints t in local time, or "-" when it is not set.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ManuL3/sessions/session"
+)
+
+func TestRun(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "sessions.db")
+
+	ctl := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		err := run(append([]string{"-db", dsn}, args...), &out)
+		return out.String(), err
+	}
+
+	if _, err := ctl("list"); err == nil || !strings.Contains(err.Error(), "migrate") {
+		t.Fatalf("expected list on an unmigrated database to ask for migrate, got %v", err)
+	}
+	if out, err := ctl("migrate"); err != nil || !strings.HasPrefix(out, "applied ") {
+		t.Fatalf("migrate = %q, %v", out, err)
+	}
+
+	store, err := session.NewDBSessionStore(dsn, "sqlite")
+	if err != nil {
+		t.Fatalf("NewDBSessionStore() error = %v", err)
+	}
+	defer store.Close()
+	first, _ := store.CreateSession("user1", time.Hour)
+	_, _ = store.CreateSession("user1", time.Hour)
+	_, _ = store.CreateSession("user2", time.Hour)
+	expired, _ := store.CreateSession("user3", -time.Minute)
+
+	t.Run("list", func(t *testing.T) {
+		out, err := ctl("-json", "list", "-user", "user1")
+		if err != nil {
+			t.Fatalf("list error = %v", err)
+		}
+		var views []sessionView
+		if err := json.Unmarshal([]byte(out), &views); err != nil {
+			t.Fatalf("decoding list: %v", err)
+		}
+		if len(views) != 2 {
+			t.Errorf("expected 2 sessions of user1, got %d", len(views))
+		}
+
+		out, _ = ctl("list", "-expired", "-limit", "10")
+		if lines := strings.Count(out, "\n"); lines != 5 {
+			t.Errorf("expected a header and 4 sessions, got %d lines:\n%s", lines, out)
+		}
+
+		// Sessions are listed by ref; their IDs need -show-ids.
+		if !strings.Contains(out, session.RedactSessionID(first.ID)) || strings.Contains(out, first.ID) {
+			t.Errorf("expected the ref but not the ID of the session:\n%s", out)
+		}
+		if out, _ := ctl("-show-ids", "list"); !strings.Contains(out, first.ID) {
+			t.Errorf("expected -show-ids to print the ID:\n%s", out)
+		}
+	})
+
+	t.Run("inspect", func(t *testing.T) {
+		for _, arg := range []string{session.RedactSessionID(expired.ID), expired.ID} {
+			out, err := ctl("inspect", arg)
+			if err != nil {
+				t.Fatalf("inspect error = %v", err)
+			}
+			if !strings.Contains(out, "user3") || !strings.Contains(out, "true") || strings.Contains(out, expired.ID) {
+				t.Errorf("unexpected inspect output:\n%s", out)
+			}
+		}
+		if _, err := ctl("inspect", "0123456789ab"); err == nil {
+			t.Error("expected an error for an unknown ref")
+		}
+		if _, err := ctl("inspect", "unknown"); err == nil {
+			t.Error("expected an error for an unknown session")
+		}
+	})
+
+	t.Run("counts", func(t *testing.T) {
+		out, err := ctl("-json", "counts")
+		if err != nil {
+			t.Fatalf("counts error = %v", err)
+		}
+		var counts sessionCounts
+		_ = json.Unmarshal([]byte(out), &counts)
+		want := sessionCounts{Total: 4, Active: 3, Expired: 1, Users: 2}
+		if counts != want {
+			t.Errorf("counts = %+v, want %+v", counts, want)
+		}
+	})
+
+	t.Run("ages", func(t *testing.T) {
+		out, err := ctl("ages")
+		if err != nil {
+			t.Fatalf("ages error = %v", err)
+		}
+		if !strings.Contains(out, "< 1h") || !strings.Contains(out, strings.Repeat("#", histogramWidth)) {
+			t.Errorf("unexpected histogram:\n%s", out)
+		}
+	})
+
+	t.Run("export and import", func(t *testing.T) {
+		export, err := ctl("export", "-skip-expired")
+		if err != nil {
+			t.Fatalf("export error = %v", err)
+		}
+		if lines := strings.Count(export, "\n"); lines != 4 {
+			t.Errorf("expected a header and 3 sessions, got %d lines", lines)
+		}
+
+		file := filepath.Join(t.TempDir(), "sessions.jsonl")
+		if err := os.WriteFile(file, []byte(export), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if out, err := ctl("import", file); err != nil || out != "imported 3 sessions\n" {
+			t.Errorf("import = %q, %v", out, err)
+		}
+
+		tenantFile := filepath.Join(t.TempDir(), "tenant.jsonl")
+		tenantExport := `{"format":"sessions","version":1}` + "\n" +
+			`{"id":"imported-into-acme","user_id":"user9","expires_at":"2999-01-01T00:00:00Z"}` + "\n"
+		if err := os.WriteFile(tenantFile, []byte(tenantExport), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if out, err := ctl("-tenant", "acme", "import", tenantFile); err != nil || out != "imported 1 sessions\n" {
+			t.Errorf("import into a tenant = %q, %v", out, err)
+		}
+		if imported, err := store.ForTenant("acme").GetSession("imported-into-acme"); err != nil || imported.Tenant != "acme" {
+			t.Errorf("expected the session imported into acme, got %v", err)
+		}
+		_ = store.DeleteSession("imported-into-acme")
+
+		// With -rehash-ids the session is imported under a new ID.
+		if out, err := ctl("import", "-rehash-ids", tenantFile); err != nil || out != "imported 1 sessions\n" {
+			t.Errorf("import -rehash-ids = %q, %v", out, err)
+		}
+		if _, err := store.GetSession("imported-into-acme"); err == nil {
+			t.Error("expected the exported ID to stop working")
+		}
+		if out, err := ctl("revoke", "-user", "user9"); err != nil || out != "revoked 1 sessions\n" {
+			t.Errorf("expected the rehashed session of user9, got %q, %v", out, err)
+		}
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		if out, err := ctl("revoke", session.RedactSessionID(first.ID)); err != nil || out != "revoked 1 sessions\n" {
+			t.Errorf("revoke = %q, %v", out, err)
+		}
+		_ = store.SaveRememberToken(&session.RememberToken{Selector: "selector", ValidatorHash: "hash", UserID: "user1", ExpiresAt: time.Now().Add(time.Hour)})
+		if out, err := ctl("revoke", "-user", "user1"); err != nil || out != "revoked 1 sessions\n" {
+			t.Errorf("revoke -user = %q, %v", out, err)
+		}
+		if _, err := store.GetRememberToken("selector"); err == nil {
+			t.Error("expected revoke -user to delete the remember-me tokens of the user")
+		}
+		if _, err := ctl("revoke", first.ID); err == nil {
+			t.Error("expected an error when revoking an unknown session")
+		}
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		if out, err := ctl("purge"); err != nil || out != "deleted 1 expired sessions\n" {
+			t.Errorf("purge = %q, %v", out, err)
+		}
+	})
+}
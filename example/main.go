@@ -4,7 +4,9 @@ import (
 	"encoding/gob"
 	"github.com/ManuL3/sessions/session"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
 	"time"
 )
 
@@ -15,28 +17,32 @@ func main() {
 		log.Fatalf("Failed to initialize session store: %v", err)
 	}
 
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	store.Logger = logger
+
 	sessionCtrl := session.Session{}
 	sessionCtrl.Store = store
+	sessionCtrl.Logger = logger
 
 	gob.Register(session.SessionData{})
 
 	// Example HTTP server
 	mux := http.NewServeMux()
-	mux.HandleFunc("/login", LoginHandler(store))
+	mux.HandleFunc("/login", LoginHandler(&sessionCtrl))
 	mux.Handle("/protected", sessionCtrl.ValidateSession(ProtectedHandler()))
+	mux.Handle("/logout", sessionCtrl.LogoutHandler(""))
 
 	log.Println("Serving on :8080")
 	log.Fatal(http.ListenAndServe(":8080", mux))
 }
 
-func LoginHandler(store session.SessionStore) http.HandlerFunc {
+func LoginHandler(sessionCtrl *session.Session) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		s, err := store.CreateSession("user123", 30*time.Minute)
+		s, err := sessionCtrl.Start(w, r, "user123", session.StartOptions{Duration: 30 * time.Minute})
 		if err != nil {
 			http.Error(w, "Error creating session", http.StatusInternalServerError)
 			return
 		}
-		session.SetSessionCookie(s.ID, w)
 		w.Write([]byte("Session: " + s.ID))
 	}
 }
@@ -44,20 +50,16 @@ func LoginHandler(store session.SessionStore) http.HandlerFunc {
 func ProtectedHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		sessionData := r.Context().Value(session.CookieName)
-		log.Println(sessionData)
-		if sessionData == nil {
+		sessionData, ok := session.GetSessionFromContext(r.Context())
+		if !ok || sessionData.UserID == "" {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		sessionDataConverted := sessionData.(*session.SessionData)
-
-		if sessionDataConverted.UserID == "" {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
+		slog.Info("protected resource accessed",
+			slog.String("session", session.RedactSessionID(sessionData.ID)),
+			slog.String("user", sessionData.UserID))
 
-		w.Write([]byte("Hello, " + sessionDataConverted.UserID))
+		w.Write([]byte("Hello, " + sessionData.UserID))
 	}
 }
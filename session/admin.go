@@ -0,0 +1,333 @@
+package session
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultAdminPageSize is the number of sessions listed per page when the
+// request does not set a limit. MaxAdminPageSize caps the limit a request
+// may ask for.
+const (
+	DefaultAdminPageSize = 50
+	MaxAdminPageSize     = 1000
+)
+
+// AdminAuthorizer decides whether a request may use the admin API.
+type AdminAuthorizer interface {
+	AuthorizeAdmin(r *http.Request) bool
+}
+
+// AdminAuthorizerFunc adapts an ordinary function to the AdminAuthorizer interface.
+type AdminAuthorizerFunc func(r *http.Request) bool
+
+// AuthorizeAdmin calls f(r).
+func (f AdminAuthorizerFunc) AuthorizeAdmin(r *http.Request) bool {
+	return f(r)
+}
+
+// AdminRoles authorizes requests whose session holds at least one of the
+// given roles. The Admin handler must then be wrapped with ValidateSession or
+// LoadSession, so that the session is in the request context.
+func AdminRoles(roles ...string) AdminAuthorizer {
+	return AdminAuthorizerFunc(func(r *http.Request) bool {
+		for _, role := range RolesFromContext(r.Context()) {
+			if slices.Contains(roles, role) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Admin is an HTTP handler serving a JSON API to inspect and revoke the
+// sessions of Store:
+//
+//	GET    /sessions?user=&limit=&after=&expired=true
+//	GET    /sessions/{ref}
+//	DELETE /sessions/{ref}
+//	DELETE /users/{user}/sessions
+//	POST   /cleanup
+//
+// Mount it below a prefix with http.StripPrefix. Sessions are referenced by
+// RedactSessionID, never by their ID, which is a credential. The API requires
+// a SessionLister store.
+type Admin struct {
+	Store SessionStore
+
+	// Authorizer decides who may use the API. Without one, every request is
+	// refused.
+	Authorizer AdminAuthorizer
+
+	// RememberStore, if set, holds the remember-me tokens of Session.RememberMe.
+	// Revoking the sessions of a user then deletes the user's tokens too, so
+	// the user cannot be logged back in by a remember-me cookie.
+	RememberStore RememberStore
+
+	// Logger, if set, receives store failures.
+	Logger *slog.Logger
+
+	once    sync.Once
+	mux     *http.ServeMux
+	cursors cipher.AEAD
+}
+
+// adminSession is the JSON representation of a session. Values are left
+// out, since they hold secrets such as the CSRF key.
+type adminSession struct {
+	Ref            string    `json:"ref"`
+	UserID         string    `json:"user_id"`
+	Tenant         string    `json:"tenant,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	LastSeenAt     time.Time `json:"last_seen_at"`
+	Expired        bool      `json:"expired"`
+	CreatedIP      string    `json:"created_ip,omitempty"`
+	LastIP         string    `json:"last_ip,omitempty"`
+	UserAgent      string    `json:"user_agent,omitempty"`
+	Device         string    `json:"device,omitempty"`
+	AuthLevel      AuthLevel `json:"auth_level"`
+	Roles          []string  `json:"roles,omitempty"`
+	Scopes         []string  `json:"scopes,omitempty"`
+	ImpersonatorID string    `json:"impersonator_id,omitempty"`
+}
+
+func newAdminSession(session *SessionData) adminSession {
+	return adminSession{
+		Ref:            RedactSessionID(session.ID),
+		UserID:         session.UserID,
+		Tenant:         session.Tenant,
+		CreatedAt:      session.CreatedAt,
+		ExpiresAt:      session.ExpiresAt,
+		LastSeenAt:     session.lastSeen(),
+		Expired:        session.ExpiresAt.Before(time.Now()),
+		CreatedIP:      session.CreatedIP,
+		LastIP:         session.LastIP,
+		UserAgent:      session.UserAgent,
+		Device:         session.Device,
+		AuthLevel:      session.AuthLevel,
+		Roles:          session.Roles,
+		Scopes:         session.Scopes,
+		ImpersonatorID: session.ImpersonatorID,
+	}
+}
+
+// ServeHTTP authorizes the request and dispatches it to its endpoint.
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.once.Do(func() {
+		a.mux = http.NewServeMux()
+		a.mux.HandleFunc("GET /sessions", a.listSessions)
+		a.mux.HandleFunc("GET /sessions/{ref}", a.getSession)
+		a.mux.HandleFunc("DELETE /sessions/{ref}", a.revokeSession)
+		a.mux.HandleFunc("DELETE /users/{user}/sessions", a.revokeUserSessions)
+		a.mux.HandleFunc("POST /cleanup", a.cleanup)
+
+		key := make([]byte, 32)
+		_, _ = rand.Read(key)
+		block, _ := aes.NewCipher(key)
+		a.cursors, _ = cipher.NewGCM(block)
+	})
+
+	if a.Authorizer == nil || !a.Authorizer.AuthorizeAdmin(r) {
+		writeAdminError(w, http.StatusForbidden, forbiddenMessage)
+		return
+	}
+	a.mux.ServeHTTP(w, r)
+}
+
+// store returns the store scoped to the tenant of r, if any.
+func (a *Admin) store(r *http.Request) SessionStore {
+	if tenant, ok := TenantFromContext(r.Context()); ok {
+		if scoper, ok := a.Store.(TenantScoper); ok {
+			return scoper.ForTenant(tenant)
+		}
+	}
+	return a.Store
+}
+
+func (a *Admin) listSessions(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := SessionQuery{
+		UserID:         params.Get("user"),
+		Limit:          DefaultAdminPageSize,
+		IncludeExpired: params.Get("expired") == "true",
+	}
+	if after := params.Get("after"); after != "" {
+		id, err := a.openCursor(after)
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, "invalid after cursor")
+			return
+		}
+		query.After = id
+	}
+	if limit := params.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 || n > MaxAdminPageSize {
+			writeAdminError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(MaxAdminPageSize))
+			return
+		}
+		query.Limit = n
+	}
+
+	sessions, err := listSessions(a.store(r), query)
+	if err != nil {
+		a.fail(w, "listing sessions failed", err)
+		return
+	}
+
+	page := struct {
+		Sessions []adminSession `json:"sessions"`
+		// Next is the after parameter of the next page, empty on the last page.
+		Next string `json:"next,omitempty"`
+	}{Sessions: make([]adminSession, 0, len(sessions))}
+	for _, session := range sessions {
+		page.Sessions = append(page.Sessions, newAdminSession(session))
+	}
+	if len(sessions) == query.Limit {
+		page.Next = a.sealCursor(sessions[len(sessions)-1].ID)
+	}
+	writeAdminJSON(w, http.StatusOK, page)
+}
+
+// sealCursor encrypts the ID a page ended at, so that the next parameter of
+// a listing does not reveal it. Cursors are only valid for the Admin, and the
+// process, that issued them.
+func (a *Admin) sealCursor(id string) string {
+	nonce := make([]byte, a.cursors.NonceSize())
+	_, _ = rand.Read(nonce)
+	return base64.RawURLEncoding.EncodeToString(a.cursors.Seal(nonce, nonce, []byte(id), nil))
+}
+
+// openCursor returns the ID sealed into cursor by sealCursor.
+func (a *Admin) openCursor(cursor string) (string, error) {
+	sealed, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(sealed) < a.cursors.NonceSize() {
+		return "", errors.New("malformed cursor")
+	}
+	nonce, ciphertext := sealed[:a.cursors.NonceSize()], sealed[a.cursors.NonceSize():]
+	id, err := a.cursors.Open(nil, nonce, ciphertext, nil)
+	return string(id), err
+}
+
+// findSessionByRef pages through the sessions of store, including expired
+// ones, for the one referenced by ref.
+func findSessionByRef(store SessionStore, ref string) (*SessionData, error) {
+	query := SessionQuery{Limit: MaxAdminPageSize, IncludeExpired: true}
+	for {
+		sessions, err := listSessions(store, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, session := range sessions {
+			if RedactSessionID(session.ID) == ref {
+				return session, nil
+			}
+		}
+		if len(sessions) < query.Limit {
+			return nil, ErrSessionNotFound
+		}
+		query.After = sessions[len(sessions)-1].ID
+	}
+}
+
+func (a *Admin) getSession(w http.ResponseWriter, r *http.Request) {
+	session, err := findSessionByRef(a.store(r), r.PathValue("ref"))
+	switch {
+	case errors.Is(err, ErrSessionNotFound):
+		writeAdminError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		a.fail(w, "fetching session failed", err)
+	default:
+		writeAdminJSON(w, http.StatusOK, newAdminSession(session))
+	}
+}
+
+func (a *Admin) revokeSession(w http.ResponseWriter, r *http.Request) {
+	store := a.store(r)
+	session, err := findSessionByRef(store, r.PathValue("ref"))
+	switch {
+	case errors.Is(err, ErrSessionNotFound):
+		writeAdminError(w, http.StatusNotFound, err.Error())
+		return
+	case err != nil:
+		a.fail(w, "fetching session failed", err)
+		return
+	}
+
+	if err := store.DeleteSession(session.ID); err != nil {
+		a.fail(w, "revoking session failed", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeUserSessions deletes the sessions of a user one by one through the
+// store, so that store wrappers see every revocation, and then the user's
+// remember-me tokens in the tenant of the request and of every revoked
+// session.
+func (a *Admin) revokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("user")
+	store := a.store(r)
+	sessions, err := listSessions(store, SessionQuery{UserID: userID, IncludeExpired: true})
+	if err != nil {
+		a.fail(w, "listing sessions failed", err)
+		return
+	}
+
+	tenant, _ := TenantFromContext(r.Context())
+	tenants := []string{tenant}
+	for _, session := range sessions {
+		if err := store.DeleteSession(session.ID); err != nil {
+			a.fail(w, "revoking session failed", err)
+			return
+		}
+		if !slices.Contains(tenants, session.Tenant) {
+			tenants = append(tenants, session.Tenant)
+		}
+	}
+
+	if a.RememberStore != nil {
+		for _, tenant := range tenants {
+			if err := a.RememberStore.DeleteUserRememberTokens(tenant, userID); err != nil {
+				a.fail(w, "revoking remember-me tokens failed", err)
+				return
+			}
+		}
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]int{"revoked": len(sessions)})
+}
+
+func (a *Admin) cleanup(w http.ResponseWriter, r *http.Request) {
+	deleted, err := (&Janitor{Store: a.store(r)}).Sweep()
+	if err != nil {
+		a.fail(w, "session cleanup failed", err)
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]int{"deleted": deleted})
+}
+
+// fail logs a store failure and answers with 500, without exposing err.
+func (a *Admin) fail(w http.ResponseWriter, message string, err error) {
+	loggerOrDiscard(a.Logger).Error(message, slog.Any("error", err))
+	writeAdminError(w, http.StatusInternalServerError, message)
+}
+
+func writeAdminJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeAdminError(w http.ResponseWriter, status int, message string) {
+	writeAdminJSON(w, status, map[string]string{"error": message})
+}
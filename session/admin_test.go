@@ -0,0 +1,238 @@
+package session
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStores_ListSessions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store SessionStore) {
+		for i := 0; i < 5; i++ {
+			_, _ = store.CreateSession("user1", time.Hour)
+		}
+		_, _ = store.CreateSession("user1", -time.Minute)
+		_, _ = store.CreateSession("user2", time.Hour)
+		_, _ = store.(TenantScoper).ForTenant("acme").CreateSession("user1", time.Hour)
+
+		// Page through the active sessions of user1.
+		var seen []string
+		query := SessionQuery{UserID: "user1", Limit: 2}
+		for {
+			page, err := listSessions(store, query)
+			if err != nil {
+				t.Fatalf("ListSessions() error = %v", err)
+			}
+			for _, session := range page {
+				if session.UserID != "user1" {
+					t.Errorf("listed session of %q", session.UserID)
+				}
+				if len(seen) > 0 && session.ID <= seen[len(seen)-1] {
+					t.Error("sessions not ordered by ID")
+				}
+				seen = append(seen, session.ID)
+			}
+			if len(page) < query.Limit {
+				break
+			}
+			query.After = page[len(page)-1].ID
+		}
+		if len(seen) != 6 {
+			t.Errorf("expected 6 active sessions of user1, got %d", len(seen))
+		}
+
+		all, _ := listSessions(store, SessionQuery{IncludeExpired: true})
+		if len(all) != 8 {
+			t.Errorf("expected 8 sessions including expired, got %d", len(all))
+		}
+
+		scoped, _ := listSessions(store.(TenantScoper).ForTenant("acme"), SessionQuery{})
+		if len(scoped) != 1 || scoped[0].Tenant != "acme" {
+			t.Errorf("expected the single acme session, got %d", len(scoped))
+		}
+	})
+}
+
+func TestAdmin(t *testing.T) {
+	store := NewInMemorySessionStore()
+	admin := &Admin{
+		Store:      store,
+		Authorizer: AdminAuthorizerFunc(func(r *http.Request) bool { return r.Header.Get("X-Admin") == "yes" }),
+	}
+
+	first, _ := store.CreateSession("user1", time.Hour)
+	second, _ := store.CreateSession("user1", time.Hour)
+	other, _ := store.CreateSession("user2", time.Hour)
+	first.Values = map[string]string{csrfSecretKey: "secret"}
+	_ = store.UpdateSession(first)
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("X-Admin", "yes")
+		rr := httptest.NewRecorder()
+		admin.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("unauthorized", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		admin.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/sessions", nil))
+		if rr.Code != http.StatusForbidden {
+			t.Errorf("expected 403, got %d", rr.Code)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/sessions?user=user1&limit=1")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rr.Code)
+		}
+
+		var page struct {
+			Sessions []map[string]any `json:"sessions"`
+			Next     string           `json:"next"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&page); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		if len(page.Sessions) != 1 || page.Next == "" {
+			t.Fatalf("expected one session and a next page, got %d and %q", len(page.Sessions), page.Next)
+		}
+		for _, field := range []string{"id", "values"} {
+			if _, ok := page.Sessions[0][field]; ok {
+				t.Errorf("session %s must not be exposed", field)
+			}
+		}
+		for _, session := range []*SessionData{first, second} {
+			if strings.Contains(rr.Body.String(), session.ID) || strings.Contains(page.Next, session.ID) {
+				t.Error("session IDs must not be exposed")
+			}
+		}
+
+		// The next cursor continues with the other session of user1.
+		rr = serve(http.MethodGet, "/sessions?user=user1&limit=1&after="+page.Next)
+		var next struct {
+			Sessions []adminSession `json:"sessions"`
+		}
+		_ = json.NewDecoder(rr.Body).Decode(&next)
+		if len(next.Sessions) != 1 || next.Sessions[0].Ref == page.Sessions[0]["ref"] {
+			t.Errorf("expected the other session on the next page, got %+v", next.Sessions)
+		}
+
+		if rr := serve(http.MethodGet, "/sessions?after="+first.ID); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for a forged cursor, got %d", rr.Code)
+		}
+
+		if rr := serve(http.MethodGet, "/sessions?limit=0"); rr.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for an invalid limit, got %d", rr.Code)
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		rr := serve(http.MethodGet, "/sessions/"+RedactSessionID(other.ID))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rr.Code)
+		}
+		var session adminSession
+		_ = json.NewDecoder(rr.Body).Decode(&session)
+		if session.Ref != RedactSessionID(other.ID) || session.UserID != "user2" {
+			t.Errorf("unexpected session %+v", session)
+		}
+
+		if rr := serve(http.MethodGet, "/sessions/unknown"); rr.Code != http.StatusNotFound {
+			t.Errorf("expected 404, got %d", rr.Code)
+		}
+	})
+
+	t.Run("revoke", func(t *testing.T) {
+		if rr := serve(http.MethodDelete, "/sessions/"+other.ID); rr.Code != http.StatusNotFound {
+			t.Errorf("expected 404 when revoking by ID, got %d", rr.Code)
+		}
+		if rr := serve(http.MethodDelete, "/sessions/"+RedactSessionID(other.ID)); rr.Code != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d", rr.Code)
+		}
+		if _, err := store.GetSession(other.ID); err == nil {
+			t.Error("expected the session to be revoked")
+		}
+	})
+
+	t.Run("revoke user", func(t *testing.T) {
+		rr := serve(http.MethodDelete, "/users/user1/sessions")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rr.Code)
+		}
+		if body := rr.Body.String(); body != "{\"revoked\":2}\n" {
+			t.Errorf("unexpected body %q", body)
+		}
+		for _, id := range []string{first.ID, second.ID} {
+			if _, err := store.GetSession(id); err == nil {
+				t.Error("expected all sessions of user1 to be revoked")
+			}
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		_, _ = store.CreateSession("user3", -time.Minute)
+		rr := serve(http.MethodPost, "/cleanup")
+		if body := rr.Body.String(); body != "{\"deleted\":1}\n" {
+			t.Errorf("unexpected body %q", body)
+		}
+	})
+}
+
+func TestAdmin_RevokeUserForgetsRememberMe(t *testing.T) {
+	store := NewInMemorySessionStore()
+	s := &Session{Store: store, RememberMe: &RememberMe{Store: store}}
+	admin := &Admin{Store: store, RememberStore: store, Authorizer: AdminAuthorizerFunc(func(r *http.Request) bool { return true })}
+
+	login := httptest.NewRecorder()
+	sessionData, err := s.Start(login, httptest.NewRequest(http.MethodPost, "/login", nil), "user1", StartOptions{Remember: true})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	rr := httptest.NewRecorder()
+	admin.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/users/user1/sessions", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	// Replaying the cookies of the revoked login does not log the user back in.
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: CookieName, Value: sessionData.ID})
+	req.AddCookie(rememberCookie(login))
+	replay := httptest.NewRecorder()
+	s.ValidateSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(replay, req)
+	if replay.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 after revoking the user, got %d", replay.Code)
+	}
+}
+
+func TestAdminRoles(t *testing.T) {
+	authorizer := AdminRoles("admin", "support")
+
+	tests := []struct {
+		name  string
+		roles []string
+		want  bool
+	}{
+		{"matching role", []string{"user", "support"}, true},
+		{"no matching role", []string{"user"}, false},
+		{"no session", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/sessions", nil)
+			if tt.roles != nil {
+				req = req.WithContext(WithSession(req.Context(), &SessionData{Roles: tt.roles}))
+			}
+			if got := authorizer.AuthorizeAdmin(req); got != tt.want {
+				t.Errorf("AuthorizeAdmin() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
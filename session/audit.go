@@ -0,0 +1,318 @@
+package session
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditEventType names a session lifecycle event.
+type AuditEventType string
+
+const (
+	AuditSessionCreated      AuditEventType = "session.created"
+	AuditSessionRotated      AuditEventType = "session.rotated"
+	AuditSessionRevoked      AuditEventType = "session.revoked"
+	AuditSessionExpired      AuditEventType = "session.expired"
+	AuditSessionTampered     AuditEventType = "session.tampered"
+	AuditFingerprintMismatch AuditEventType = "session.fingerprint_mismatch"
+)
+
+// Audit reasons set by the Session controller.
+const (
+	auditReasonLogin              = "login"
+	auditReasonLogout             = "logout"
+	auditReasonRotated            = "rotated"
+	auditReasonRemembered         = "remember_me"
+	auditReasonCleanup            = "cleanup"
+	auditReasonMalformedToken     = "malformed_token"
+	auditReasonCrossTenant        = "cross_tenant"
+	auditReasonRememberTheft      = "remember_token_reused"
+	auditReasonImpersonationStart = "impersonation_started"
+	auditReasonImpersonationEnd   = "impersonation_ended"
+	auditReasonSessionLimit       = "session_limit"
+)
+
+// AuditEvent is a single entry of the audit trail. Sessions are referenced
+// by RedactSessionID, never by their ID.
+type AuditEvent struct {
+	Time    time.Time      `json:"time"`
+	Type    AuditEventType `json:"type"`
+	Session string         `json:"session"`
+	// Previous is the session replaced by a rotation.
+	Previous string `json:"previous,omitempty"`
+	UserID   string `json:"user_id,omitempty"`
+	// Actor is the user who caused the event. For impersonation sessions it
+	// is the admin, not the impersonated user.
+	Actor  string `json:"actor,omitempty"`
+	Tenant string `json:"tenant,omitempty"`
+	IP     string `json:"ip,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// AuditSink receives the audit trail. Implementations must only ever append.
+type AuditSink interface {
+	WriteAuditEvent(event AuditEvent) error
+}
+
+// AuditStore wraps a store so that sessions it creates, revokes and expires
+// are written to sink. The Session controller audits its store calls itself
+// when its Audit field is set, adding the client IP and actor of the request,
+// so AuditStore is meant for code using a store directly, such as cleanup
+// jobs. Using both records these events twice.
+func AuditStore(store SessionStore, sink AuditSink) SessionStore {
+	return &auditedStore{store: store, sink: sink}
+}
+
+// auditedStore writes lifecycle events of store operations, attributed to
+// actor and ip when made on behalf of a request.
+type auditedStore struct {
+	store  SessionStore
+	sink   AuditSink
+	logger *slog.Logger
+
+	actor  string
+	ip     string
+	reason string
+}
+
+func (s *auditedStore) write(event AuditEvent) {
+	event.Time = time.Now()
+	if event.Actor == "" {
+		event.Actor = s.actor
+	}
+	event.IP = s.ip
+	if event.Reason == "" {
+		event.Reason = s.reason
+	}
+
+	if err := s.sink.WriteAuditEvent(event); err != nil {
+		loggerOrDiscard(s.logger).Error("writing audit event failed", slog.String("type", string(event.Type)), slog.Any("error", err))
+	}
+}
+
+func (s *auditedStore) CreateSession(userID string, duration time.Duration) (*SessionData, error) {
+	session, _, err := s.CreateSessionEvicting(userID, duration)
+	return session, err
+}
+
+// CreateSessionEvicting also records the sessions evicted by the session
+// limit of the wrapped store as revoked.
+func (s *auditedStore) CreateSessionEvicting(userID string, duration time.Duration) (*SessionData, []*SessionData, error) {
+	session, evicted, err := createSessionEvicting(s.store, userID, duration)
+	for _, old := range evicted {
+		s.write(AuditEvent{Type: AuditSessionRevoked, Session: RedactSessionID(old.ID), UserID: old.UserID, Tenant: old.Tenant, Reason: auditReasonSessionLimit})
+	}
+	if err == nil {
+		actor := s.actor
+		if actor == "" {
+			actor = userID
+		}
+		s.write(AuditEvent{Type: AuditSessionCreated, Session: RedactSessionID(session.ID), UserID: userID, Actor: actor, Tenant: session.Tenant})
+	}
+	return session, evicted, err
+}
+
+// GetSession removes a session it finds expired, so that its expiry is
+// recorded once rather than on every lookup until the next cleanup.
+func (s *auditedStore) GetSession(sessionID string) (*SessionData, error) {
+	session, err := s.store.GetSession(sessionID)
+	if errors.Is(err, ErrSessionExpired) && s.store.DeleteSession(sessionID) == nil {
+		s.write(AuditEvent{Type: AuditSessionExpired, Session: RedactSessionID(sessionID)})
+	}
+	return session, err
+}
+
+func (s *auditedStore) UpdateSession(session *SessionData) error {
+	return updateSession(s.store, session)
+}
+
+func (s *auditedStore) TouchSession(sessionID string, activity SessionActivity) error {
+	return touchSession(s.store, sessionID, activity)
+}
+
+// DeleteSession looks the session up first, so that the event names its
+// user and revoking an unknown session records nothing.
+func (s *auditedStore) DeleteSession(sessionID string) error {
+	session, lookupErr := s.store.GetSession(sessionID)
+
+	if err := s.store.DeleteSession(sessionID); err != nil {
+		return err
+	}
+
+	if lookupErr == nil {
+		s.write(AuditEvent{Type: AuditSessionRevoked, Session: RedactSessionID(sessionID), UserID: session.UserID, Tenant: session.Tenant})
+	}
+	return nil
+}
+
+func (s *auditedStore) CleanupExpiredSessions() error {
+	_, err := s.PurgeExpiredSessions()
+	return err
+}
+
+// PurgeExpiredSessions records an expiry for every purged session when the
+// wrapped store implements ExpiredSessionPurger.
+func (s *auditedStore) PurgeExpiredSessions() ([]*SessionData, error) {
+	purger, ok := s.store.(ExpiredSessionPurger)
+	if !ok {
+		return nil, s.store.CleanupExpiredSessions()
+	}
+
+	purged, err := purger.PurgeExpiredSessions()
+	for _, session := range purged {
+		s.write(AuditEvent{
+			Type:    AuditSessionExpired,
+			Session: RedactSessionID(session.ID),
+			UserID:  session.UserID,
+			Tenant:  session.Tenant,
+			Reason:  auditReasonCleanup,
+		})
+	}
+	return purged, err
+}
+
+func (s *auditedStore) CountActiveSessions() (int, error) {
+	return countActiveSessions(s.store)
+}
+
+func (s *auditedStore) ListSessions(query SessionQuery) ([]*SessionData, error) {
+	return listSessions(s.store, query)
+}
+
+func (s *auditedStore) ImportSession(session *SessionData) error {
+	return importSession(s.store, session)
+}
+
+// ForTenant audits the tenant view of the wrapped store.
+func (s *auditedStore) ForTenant(tenant string) SessionStore {
+	scoped := *s
+	if scoper, ok := s.store.(TenantScoper); ok {
+		scoped.store = scoper.ForTenant(tenant)
+	}
+	return &scoped
+}
+
+// audit writes an event detected while handling r to the audit sink of the
+// controller. Failing to write it does not fail the request.
+func (s *Session) audit(r *http.Request, event AuditEvent) {
+	if s.Audit == nil {
+		return
+	}
+
+	event.Time = time.Now()
+	if event.Actor == "" {
+		event.Actor = auditActor(r)
+	}
+	if event.IP == "" {
+		if addr, ok := ClientIP(r, s.TrustedProxies); ok {
+			event.IP = addr.String()
+		}
+	}
+	if event.Tenant == "" {
+		event.Tenant, _ = TenantFromContext(r.Context())
+	}
+
+	if err := s.Audit.WriteAuditEvent(event); err != nil {
+		s.logger().Error("writing audit event failed", slog.String("type", string(event.Type)), slog.Any("error", err))
+	}
+}
+
+// auditedStore wraps store so that its lifecycle events are attributed to
+// the client and user of r.
+func (s *Session) auditedStore(r *http.Request, store SessionStore, reason string) SessionStore {
+	audited := &auditedStore{store: store, sink: s.Audit, logger: s.Logger, actor: auditActor(r), reason: reason}
+	if addr, ok := ClientIP(r, s.TrustedProxies); ok {
+		audited.ip = addr.String()
+	}
+	return audited
+}
+
+// auditActor returns the user acting in r: the admin during impersonation,
+// the session user otherwise.
+func auditActor(r *http.Request) string {
+	sessionData, ok := GetSessionFromContext(r.Context())
+	if !ok {
+		return ""
+	}
+	if sessionData.IsImpersonation() {
+		return sessionData.ImpersonatorID
+	}
+	return sessionData.UserID
+}
+
+// isSessionIDFormat reports whether token could have been issued by
+// generateSessionID. Tokens that could not are tampered with.
+func isSessionIDFormat(token string) bool {
+	if len(token) != sessionIDLength {
+		return false
+	}
+	for i := 0; i < len(token); i++ {
+		c := token[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// FileAuditSink appends audit events to a file as JSON lines.
+type FileAuditSink struct {
+	mutex   sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// NewFileAuditSink opens path for appending, creating it if needed.
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAuditSink{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// WriteAuditEvent appends event as a single line.
+func (f *FileAuditSink) WriteAuditEvent(event AuditEvent) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.encoder.Encode(event)
+}
+
+// Close closes the underlying file.
+func (f *FileAuditSink) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.file.Close()
+}
+
+// SQLAuditSink appends audit events to the session_audit table.
+type SQLAuditSink struct {
+	db *sql.DB
+}
+
+// NewSQLAuditSink writes to the session_audit table of db. The table is
+// created by the migrations of DBSessionStore, which must have been applied.
+func NewSQLAuditSink(db *sql.DB) (*SQLAuditSink, error) {
+	rows, err := db.Query(`SELECT 1 FROM session_audit LIMIT 0`)
+	if err != nil {
+		return nil, fmt.Errorf("session_audit table missing, migrate the database first: %w", err)
+	}
+	rows.Close()
+	return &SQLAuditSink{db: db}, nil
+}
+
+// WriteAuditEvent inserts event into the session_audit table.
+func (s *SQLAuditSink) WriteAuditEvent(event AuditEvent) error {
+	_, err := s.db.Exec(`
+		INSERT INTO session_audit (time, type, session, previous, user_id, actor, tenant, ip, reason)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, event.Time, string(event.Type), event.Session, event.Previous, event.UserID, event.Actor, event.Tenant, event.IP, event.Reason)
+	return err
+}
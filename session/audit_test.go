@@ -0,0 +1,301 @@
+package session
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingSink keeps audit events in memory.
+type recordingSink struct {
+	mutex  sync.Mutex
+	events []AuditEvent
+}
+
+func (s *recordingSink) WriteAuditEvent(event AuditEvent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+// take returns the recorded events and forgets them.
+func (s *recordingSink) take() []AuditEvent {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	events := s.events
+	s.events = nil
+	return events
+}
+
+func TestSession_AuditTrail(t *testing.T) {
+	sink := &recordingSink{}
+	store := NewInMemorySessionStore()
+	s := &Session{Store: store, Audit: sink, TouchInterval: -1}
+
+	// request builds a request from 192.0.2.1 carrying token and, when
+	// given, the session it belongs to.
+	request := func(token string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		if token != "" {
+			req.AddCookie(&http.Cookie{Name: CookieName, Value: token})
+			if sessionData, err := store.GetSession(token); err == nil {
+				req = req.WithContext(WithSession(req.Context(), sessionData))
+			}
+		}
+		return req
+	}
+
+	first, err := s.Start(httptest.NewRecorder(), request(""), "user1", StartOptions{})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	login := sink.take()
+
+	second, err := s.Start(httptest.NewRecorder(), request(first.ID), "user1", StartOptions{})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	relogin := sink.take()
+
+	if err := s.Logout(httptest.NewRecorder(), request(second.ID)); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	logout := sink.take()
+
+	s.ValidateSession(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), request("../../etc/passwd"))
+	tampered := sink.take()
+
+	tests := []struct {
+		name   string
+		events []AuditEvent
+		expect []AuditEvent
+	}{
+		{"login", login, []AuditEvent{
+			{Type: AuditSessionCreated, Session: RedactSessionID(first.ID), UserID: "user1", Actor: "user1", IP: "192.0.2.1", Reason: auditReasonLogin},
+		}},
+		{"login over a live session", relogin, []AuditEvent{
+			{Type: AuditSessionRevoked, Session: RedactSessionID(first.ID), UserID: "user1", Actor: "user1", IP: "192.0.2.1", Reason: auditReasonRotated},
+			{Type: AuditSessionCreated, Session: RedactSessionID(second.ID), UserID: "user1", Actor: "user1", IP: "192.0.2.1", Reason: auditReasonLogin},
+			{Type: AuditSessionRotated, Session: RedactSessionID(second.ID), Previous: RedactSessionID(first.ID), UserID: "user1", Actor: "user1", IP: "192.0.2.1", Reason: auditReasonLogin},
+		}},
+		{"logout", logout, []AuditEvent{
+			{Type: AuditSessionRevoked, Session: RedactSessionID(second.ID), UserID: "user1", Actor: "user1", IP: "192.0.2.1", Reason: auditReasonLogout},
+		}},
+		{"malformed token", tampered, []AuditEvent{
+			{Type: AuditSessionTampered, Session: RedactSessionID("../../etc/passwd"), IP: "192.0.2.1", Reason: auditReasonMalformedToken},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.events) != len(tt.expect) {
+				t.Fatalf("expected %d events, got %+v", len(tt.expect), tt.events)
+			}
+			for i, event := range tt.events {
+				if event.Time.IsZero() {
+					t.Errorf("event %d has no time", i)
+				}
+				event.Time = time.Time{}
+				if event != tt.expect[i] {
+					t.Errorf("event %d = %+v, want %+v", i, event, tt.expect[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSession_AuditFingerprintMismatch(t *testing.T) {
+	sink := &recordingSink{}
+	store := NewInMemorySessionStore()
+	s := &Session{Store: store, Audit: sink, Binding: ClientBinding{UserAgent: true, Policy: BindingReject}}
+
+	login := httptest.NewRequest(http.MethodPost, "/login", nil)
+	login.Header.Set("User-Agent", "original")
+	sessionData, _ := s.Start(httptest.NewRecorder(), login, "user1", StartOptions{})
+	sink.take()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "other")
+	req.AddCookie(&http.Cookie{Name: CookieName, Value: sessionData.ID})
+	s.ValidateSession(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+
+	events := sink.take()
+	if len(events) != 1 || events[0].Type != AuditFingerprintMismatch || events[0].Reason != "reject" ||
+		events[0].Session != RedactSessionID(sessionData.ID) {
+		t.Errorf("expected a fingerprint mismatch event, got %+v", events)
+	}
+}
+
+func TestAuditStore_Cleanup(t *testing.T) {
+	sink := &recordingSink{}
+	store := AuditStore(NewInMemorySessionStore(), sink)
+
+	_, _ = store.CreateSession("user1", time.Hour)
+	expired, _ := store.CreateSession("user2", -time.Minute)
+	sink.take()
+
+	if err := store.CleanupExpiredSessions(); err != nil {
+		t.Fatalf("CleanupExpiredSessions() error = %v", err)
+	}
+
+	events := sink.take()
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %+v", events)
+	}
+	if events[0].Type != AuditSessionExpired || events[0].Session != RedactSessionID(expired.ID) ||
+		events[0].UserID != "user2" || events[0].Reason != auditReasonCleanup {
+		t.Errorf("unexpected event %+v", events[0])
+	}
+}
+
+func TestAuditStore_ExpiryOnLookupRecordedOnce(t *testing.T) {
+	forEachStore(t, func(t *testing.T, backing SessionStore) {
+		sink := &recordingSink{}
+		store := AuditStore(backing, sink)
+
+		expired, _ := store.CreateSession("user1", -time.Minute)
+		sink.take()
+
+		for i := 0; i < 3; i++ {
+			_, _ = store.GetSession(expired.ID)
+		}
+		_ = store.CleanupExpiredSessions()
+
+		events := sink.take()
+		if len(events) != 1 || events[0].Type != AuditSessionExpired || events[0].Session != RedactSessionID(expired.ID) {
+			t.Errorf("expected a single expiry event, got %+v", events)
+		}
+	})
+}
+
+func TestAuditStore_LimitEvictions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, backing SessionStore) {
+		backing.(limitedStore).setLimit(SessionLimit{MaxSessions: 1})
+		sink := &recordingSink{}
+		store := AuditStore(backing, sink)
+
+		first, _ := store.CreateSession("user1", time.Hour)
+		sink.take()
+		second, _ := store.CreateSession("user1", time.Hour)
+
+		events := sink.take()
+		if len(events) != 2 {
+			t.Fatalf("expected a revocation and a creation, got %+v", events)
+		}
+		if events[0].Type != AuditSessionRevoked || events[0].Session != RedactSessionID(first.ID) ||
+			events[0].UserID != "user1" || events[0].Reason != auditReasonSessionLimit {
+			t.Errorf("unexpected eviction event %+v", events[0])
+		}
+		if events[1].Type != AuditSessionCreated || events[1].Session != RedactSessionID(second.ID) {
+			t.Errorf("unexpected creation event %+v", events[1])
+		}
+	})
+}
+
+func TestIsSessionIDFormat(t *testing.T) {
+	tests := []struct {
+		token  string
+		expect bool
+	}{
+		{generateSessionID(), true},
+		{"short", false},
+		{"abcdefghijklmnopqrstuvwxyz01234-", false},
+	}
+
+	for _, tt := range tests {
+		if got := isSessionIDFormat(tt.token); got != tt.expect {
+			t.Errorf("isSessionIDFormat(%q) = %v, want %v", tt.token, got, tt.expect)
+		}
+	}
+}
+
+func TestAuditSinks(t *testing.T) {
+	event := AuditEvent{
+		Time:    time.Now().UTC().Truncate(time.Second),
+		Type:    AuditSessionRevoked,
+		Session: RedactSessionID("id"),
+		UserID:  "user1",
+		Actor:   "admin",
+		IP:      "192.0.2.1",
+		Reason:  auditReasonLogout,
+	}
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.jsonl")
+		sink, err := NewFileAuditSink(path)
+		if err != nil {
+			t.Fatalf("NewFileAuditSink() error = %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := sink.WriteAuditEvent(event); err != nil {
+				t.Fatalf("WriteAuditEvent() error = %v", err)
+			}
+		}
+		if err := sink.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		lines := 0
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var got AuditEvent
+			if err := json.Unmarshal(scanner.Bytes(), &got); err != nil {
+				t.Fatalf("line %d is not JSON: %v", lines, err)
+			}
+			if !got.Time.Equal(event.Time) {
+				t.Errorf("expected time %v, got %v", event.Time, got.Time)
+			}
+			got.Time = event.Time
+			if got != event {
+				t.Errorf("read %+v, want %+v", got, event)
+			}
+			lines++
+		}
+		if lines != 2 {
+			t.Errorf("expected 2 lines, got %d", lines)
+		}
+	})
+
+	t.Run("sql", func(t *testing.T) {
+		unmigrated, err := OpenDBSessionStore(":memory:", "sqlite")
+		if err != nil {
+			t.Fatalf("OpenDBSessionStore() error = %v", err)
+		}
+		defer unmigrated.Close()
+		if _, err := unmigrated.AuditSink(); err == nil {
+			t.Error("expected AuditSink() to require migrations")
+		}
+
+		store := setupTestDB(t)
+		sink, err := store.AuditSink()
+		if err != nil {
+			t.Fatalf("AuditSink() error = %v", err)
+		}
+		if err := sink.WriteAuditEvent(event); err != nil {
+			t.Fatalf("WriteAuditEvent() error = %v", err)
+		}
+
+		var eventType, session, actor, reason string
+		err = store.db.QueryRow(`SELECT type, session, actor, reason FROM session_audit`).Scan(&eventType, &session, &actor, &reason)
+		if err != nil {
+			t.Fatalf("reading session_audit failed: %v", err)
+		}
+		if eventType != string(event.Type) || session != event.Session || actor != event.Actor || reason != event.Reason {
+			t.Errorf("unexpected row %q %q %q %q", eventType, session, actor, reason)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package session
+
+import (
+	"net/http"
+	"time"
+)
+
+const reauthRequiredMessage = "Reauthentication required"
+
+// AuthLevel ranks how strongly the user proved their identity. Higher
+// levels satisfy requirements for lower ones.
+type AuthLevel int
+
+const (
+	// AuthLevelNone is used for sessions resumed without credentials, for
+	// example from a remember-me token.
+	AuthLevelNone AuthLevel = iota
+	// AuthLevelPassword means the user entered their password.
+	AuthLevelPassword
+	// AuthLevelMFA means the user completed a second factor.
+	AuthLevelMFA
+)
+
+// Elevate records that the user of the request's session just completed an
+// authentication of the given level, for example after re-entering their
+// password. The level and time always describe the latest authentication, so
+// a fresh password entry never makes an old second factor look recent.
+func (s *Session) Elevate(r *http.Request, level AuthLevel) error {
+	sessionData, ok := GetSessionFromContext(r.Context())
+	if !ok {
+		return errNoSession
+	}
+
+	sessionData.AuthLevel = level
+	sessionData.AuthenticatedAt = time.Now()
+
+	return updateSession(s.store(r), sessionData)
+}
+
+// RequireAuthLevel returns a middleware that only lets requests through when
+// the session reached at least level within maxAge. Other requests are sent
+// to the ReauthHandler. A zero maxAge accepts authentications of any age.
+func (s *Session) RequireAuthLevel(level AuthLevel, maxAge time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sessionData, ok := GetSessionFromContext(r.Context())
+			if !ok {
+				http.Error(w, unauthorizedMessage, http.StatusUnauthorized)
+				return
+			}
+
+			if !sessionData.hasAuthLevel(level, maxAge) {
+				s.reauth(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// hasAuthLevel reports whether the session reached level within maxAge.
+func (d *SessionData) hasAuthLevel(level AuthLevel, maxAge time.Duration) bool {
+	if d.AuthLevel < level {
+		return false
+	}
+	return maxAge <= 0 || time.Since(d.authenticatedAt()) <= maxAge
+}
+
+func (s *Session) reauth(w http.ResponseWriter, r *http.Request) {
+	if s.ReauthHandler != nil {
+		s.ReauthHandler.ServeHTTP(w, r)
+		return
+	}
+	http.Error(w, reauthRequiredMessage, http.StatusUnauthorized)
+}
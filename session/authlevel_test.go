@@ -0,0 +1,112 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSession_RequireAuthLevel(t *testing.T) {
+	tests := []struct {
+		name       string
+		session    *SessionData
+		level      AuthLevel
+		maxAge     time.Duration
+		expectCode int
+	}{
+		{
+			name:       "no session",
+			level:      AuthLevelPassword,
+			expectCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "level reached recently",
+			session:    &SessionData{AuthLevel: AuthLevelPassword, AuthenticatedAt: time.Now()},
+			level:      AuthLevelPassword,
+			maxAge:     5 * time.Minute,
+			expectCode: http.StatusOK,
+		},
+		{
+			name:       "higher level satisfies lower",
+			session:    &SessionData{AuthLevel: AuthLevelMFA, AuthenticatedAt: time.Now()},
+			level:      AuthLevelPassword,
+			maxAge:     5 * time.Minute,
+			expectCode: http.StatusOK,
+		},
+		{
+			name:       "level too low",
+			session:    &SessionData{AuthLevel: AuthLevelPassword, AuthenticatedAt: time.Now()},
+			level:      AuthLevelMFA,
+			maxAge:     5 * time.Minute,
+			expectCode: http.StatusTeapot,
+		},
+		{
+			name:       "level too old",
+			session:    &SessionData{AuthLevel: AuthLevelMFA, AuthenticatedAt: time.Now().Add(-time.Hour)},
+			level:      AuthLevelMFA,
+			maxAge:     5 * time.Minute,
+			expectCode: http.StatusTeapot,
+		},
+		{
+			name:       "no maximum age",
+			session:    &SessionData{AuthLevel: AuthLevelMFA, AuthenticatedAt: time.Now().Add(-time.Hour)},
+			level:      AuthLevelMFA,
+			expectCode: http.StatusOK,
+		},
+	}
+
+	s := &Session{
+		Store: NewInMemorySessionStore(),
+		ReauthHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}),
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.session != nil {
+				req = req.WithContext(WithSession(req.Context(), tt.session))
+			}
+
+			rr := httptest.NewRecorder()
+			s.RequireAuthLevel(tt.level, tt.maxAge)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectCode {
+				t.Errorf("expected code %d, got %d", tt.expectCode, rr.Code)
+			}
+		})
+	}
+}
+
+func TestSession_Elevate(t *testing.T) {
+	store := NewInMemorySessionStore()
+	s := &Session{Store: store}
+
+	sessionData, err := s.Start(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/login", nil),
+		"user1", StartOptions{AuthLevel: AuthLevelPassword})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if sessionData.AuthLevel != AuthLevelPassword || sessionData.AuthenticatedAt.IsZero() {
+		t.Fatalf("Start() auth = %v at %v, want %v", sessionData.AuthLevel, sessionData.AuthenticatedAt, AuthLevelPassword)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/reauth", nil)
+	req = req.WithContext(WithSession(req.Context(), sessionData))
+	if err := s.Elevate(req, AuthLevelMFA); err != nil {
+		t.Fatalf("Elevate() error = %v", err)
+	}
+
+	stored, _ := store.GetSession(sessionData.ID)
+	if stored.AuthLevel != AuthLevelMFA {
+		t.Errorf("expected stored auth level %v, got %v", AuthLevelMFA, stored.AuthLevel)
+	}
+
+	if err := s.Elevate(httptest.NewRequest(http.MethodPost, "/reauth", nil), AuthLevelMFA); err == nil {
+		t.Error("expected Elevate() to fail without a session")
+	}
+}
@@ -0,0 +1,124 @@
+package session
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/netip"
+)
+
+// BindingPolicy decides what happens when a request does not match the
+// client a session was bound to.
+type BindingPolicy int
+
+const (
+	// BindingReject refuses the request but keeps the session valid for the
+	// client it was bound to.
+	BindingReject BindingPolicy = iota
+	// BindingReauth revokes the session, forcing the user to log in again.
+	BindingReauth
+	// BindingLogOnly logs the mismatch and accepts the request.
+	BindingLogOnly
+)
+
+// String returns the name of the policy.
+func (p BindingPolicy) String() string {
+	switch p {
+	case BindingReject:
+		return "reject"
+	case BindingReauth:
+		return "reauth"
+	case BindingLogOnly:
+		return "log_only"
+	default:
+		return "unknown"
+	}
+}
+
+// errBindingMismatch is returned when a request does not match the session's client binding.
+var errBindingMismatch = errors.New("client binding mismatch")
+
+// ClientBinding ties sessions to the client that created them. Sessions are
+// bound by Start; sessions created without binding are never checked.
+type ClientBinding struct {
+	// UserAgent binds the session to a hash of the User-Agent header.
+	UserAgent bool
+
+	// IPv4PrefixLength and IPv6PrefixLength bind the session to the client's
+	// subnet of that size, for example 24 and 64. Zero disables IP binding
+	// for that address family.
+	IPv4PrefixLength int
+	IPv6PrefixLength int
+
+	Policy BindingPolicy
+}
+
+// enabled reports whether any binding is configured.
+func (b ClientBinding) enabled() bool {
+	return b.UserAgent || b.IPv4PrefixLength > 0 || b.IPv6PrefixLength > 0
+}
+
+// bindClient records the fingerprint of the requesting client on the session.
+func (s *Session) bindClient(r *http.Request, sessionData *SessionData) {
+	if s.Binding.UserAgent {
+		sessionData.UserAgentHash = sha256Hex(r.UserAgent())
+	}
+
+	if addr, ok := ClientIP(r, s.TrustedProxies); ok {
+		bits := s.Binding.IPv6PrefixLength
+		if addr.Is4() {
+			bits = s.Binding.IPv4PrefixLength
+		}
+		if bits > 0 {
+			if prefix, err := addr.Prefix(bits); err == nil {
+				sessionData.IPNetwork = prefix.String()
+			}
+		}
+	}
+}
+
+// checkBinding enforces the binding policy for a session loaded for r.
+func (s *Session) checkBinding(r *http.Request, sessionData *SessionData) error {
+	if s.matchesBinding(r, sessionData) {
+		return nil
+	}
+
+	s.audit(r, AuditEvent{
+		Type:    AuditFingerprintMismatch,
+		Session: RedactSessionID(sessionData.ID),
+		UserID:  sessionData.UserID,
+		Reason:  s.Binding.Policy.String(),
+	})
+
+	switch s.Binding.Policy {
+	case BindingLogOnly:
+		s.logger().Warn("client binding mismatch", sessionAttr(sessionData.ID), slog.String("user", sessionData.UserID))
+		return nil
+	case BindingReauth:
+		if err := s.store(r).DeleteSession(sessionData.ID); err != nil {
+			return err
+		}
+	}
+	return errBindingMismatch
+}
+
+// matchesBinding reports whether r comes from the client the session was bound to.
+func (s *Session) matchesBinding(r *http.Request, sessionData *SessionData) bool {
+	if sessionData.UserAgentHash != "" && sessionData.UserAgentHash != sha256Hex(r.UserAgent()) {
+		return false
+	}
+
+	if sessionData.IPNetwork != "" {
+		prefix, err := netip.ParsePrefix(sessionData.IPNetwork)
+		if err != nil {
+			return false
+		}
+
+		addr, ok := ClientIP(r, s.TrustedProxies)
+		if !ok || !prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,62 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSession_ClientBinding(t *testing.T) {
+	const (
+		userAgent  = "Mozilla/5.0"
+		remoteAddr = "203.0.113.7:1234"
+	)
+
+	tests := []struct {
+		name          string
+		binding       ClientBinding
+		userAgent     string
+		remoteAddr    string
+		expectValid   bool
+		expectRevoked bool
+	}{
+		{"same client", ClientBinding{UserAgent: true, IPv4PrefixLength: 24}, userAgent, remoteAddr, true, false},
+		{"same subnet", ClientBinding{IPv4PrefixLength: 24}, userAgent, "203.0.113.99:1234", true, false},
+		{"different subnet rejected", ClientBinding{IPv4PrefixLength: 24}, userAgent, "198.51.100.7:1234", false, false},
+		{"different user agent rejected", ClientBinding{UserAgent: true}, "curl/8.0", remoteAddr, false, false},
+		{"different user agent revoked", ClientBinding{UserAgent: true, Policy: BindingReauth}, "curl/8.0", remoteAddr, false, true},
+		{"different user agent logged", ClientBinding{UserAgent: true, Policy: BindingLogOnly}, "curl/8.0", remoteAddr, true, false},
+		{"binding disabled", ClientBinding{}, "curl/8.0", "198.51.100.7:1234", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewInMemorySessionStore()
+			s := &Session{Store: store, Binding: tt.binding}
+
+			login := httptest.NewRequest(http.MethodPost, "/login", nil)
+			login.Header.Set("User-Agent", userAgent)
+			login.RemoteAddr = remoteAddr
+
+			sessionData, err := s.Start(httptest.NewRecorder(), login, "user1", StartOptions{})
+			if err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			req.RemoteAddr = tt.remoteAddr
+			req.AddCookie(&http.Cookie{Name: CookieName, Value: sessionData.ID})
+
+			_, err = s.validateAndFetchSession(req)
+			if (err == nil) != tt.expectValid {
+				t.Errorf("expected valid %v, got error %v", tt.expectValid, err)
+			}
+
+			_, err = store.GetSession(sessionData.ID)
+			if (err != nil) != tt.expectRevoked {
+				t.Errorf("expected revoked %v, got error %v", tt.expectRevoked, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package session
+
+import (
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+)
+
+// RevocationTransport carries cache invalidations between nodes. Messages
+// are cache keys, which are hashes of session IDs: session IDs themselves
+// never leave the node.
+type RevocationTransport interface {
+	// Publish announces to all nodes that the session with key changed or
+	// was revoked.
+	Publish(key string) error
+	// Subscribe calls handler for every key published by any node,
+	// including this one, until unsubscribe is called.
+	Subscribe(handler func(key string)) (unsubscribe func(), err error)
+}
+
+// InProcessTransport delivers invalidations between caches of the same
+// process, for example in tests or when several stores share a process.
+type InProcessTransport struct {
+	mutex    sync.RWMutex
+	handlers map[int]func(key string)
+	next     int
+}
+
+// NewInProcessTransport creates a transport without subscribers.
+func NewInProcessTransport() *InProcessTransport {
+	return &InProcessTransport{handlers: make(map[int]func(key string))}
+}
+
+// Publish calls every subscriber before returning.
+func (t *InProcessTransport) Publish(key string) error {
+	t.mutex.RLock()
+	handlers := make([]func(key string), 0, len(t.handlers))
+	for _, handler := range t.handlers {
+		handlers = append(handlers, handler)
+	}
+	t.mutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler(key)
+	}
+	return nil
+}
+
+// Subscribe registers handler.
+func (t *InProcessTransport) Subscribe(handler func(key string)) (func(), error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	id := t.next
+	t.next++
+	t.handlers[id] = handler
+
+	return func() {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		delete(t.handlers, id)
+	}, nil
+}
+
+// revocationPrefix starts every UDP message, so that unrelated traffic on
+// the multicast group is ignored.
+const revocationPrefix = "sessions/v1 revoke "
+
+// UDPTransport delivers invalidations over UDP multicast, so every node
+// joined to the group evicts the session. Delivery is best effort: a lost
+// datagram leaves the entry cached until the cache TTL runs out.
+type UDPTransport struct {
+	group *net.UDPAddr
+	conn  *net.UDPConn
+
+	// Interface is the network interface to join the group on. Nil lets
+	// the system choose.
+	Interface *net.Interface
+}
+
+// NewUDPTransport creates a transport for the multicast group address,
+// such as "239.255.43.21:47001".
+func NewUDPTransport(group string) (*UDPTransport, error) {
+	addr, err := net.ResolveUDPAddr("udp", group)
+	if err != nil {
+		return nil, err
+	}
+	if !addr.IP.IsMulticast() {
+		return nil, errors.New("not a multicast address: " + group)
+	}
+
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &UDPTransport{group: addr, conn: conn}, nil
+}
+
+// Publish sends key to the multicast group.
+func (t *UDPTransport) Publish(key string) error {
+	_, err := t.conn.Write([]byte(revocationPrefix + key))
+	return err
+}
+
+// Subscribe joins the multicast group and calls handler from a goroutine
+// for every valid message received.
+func (t *UDPTransport) Subscribe(handler func(key string)) (func(), error) {
+	conn, err := net.ListenMulticastUDP("udp", t.Interface, t.group)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		buf := make([]byte, 512)
+		for {
+			n, _, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+				continue
+			}
+
+			if key, ok := parseRevocation(string(buf[:n])); ok {
+				handler(key)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			conn.Close()
+			<-done
+		})
+	}, nil
+}
+
+// Close releases the sending socket.
+func (t *UDPTransport) Close() error {
+	return t.conn.Close()
+}
+
+// parseRevocation extracts the key from a revocation message.
+func parseRevocation(message string) (string, bool) {
+	key, ok := strings.CutPrefix(message, revocationPrefix)
+	if !ok || len(key) != 64 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(key); err != nil {
+		return "", false
+	}
+	return key, true
+}
@@ -0,0 +1,309 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is how long a CachedStore keeps a session when no TTL is given.
+const DefaultCacheTTL = 30 * time.Second
+
+// CachedStore keeps recently used sessions in memory in front of another
+// store. Updates and deletions are published on a RevocationTransport, and
+// every node receiving them evicts the session at once, so a revoked session
+// does not stay valid on other nodes until the TTL runs out.
+type CachedStore struct {
+	store     SessionStore
+	ttl       time.Duration
+	transport RevocationTransport
+
+	unsubscribe func()
+
+	mutex   sync.RWMutex
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is a cached session and the time it was read from the store.
+type cacheEntry struct {
+	session  *SessionData
+	cachedAt time.Time
+}
+
+// NewCachedStore creates a cache in front of store. A zero ttl means
+// DefaultCacheTTL. A nil transport limits invalidation to this node.
+func NewCachedStore(store SessionStore, ttl time.Duration, transport RevocationTransport) (*CachedStore, error) {
+	if ttl == 0 {
+		ttl = DefaultCacheTTL
+	}
+
+	c := &CachedStore{
+		store:     store,
+		ttl:       ttl,
+		transport: transport,
+		entries:   make(map[string]cacheEntry),
+	}
+
+	if transport != nil {
+		unsubscribe, err := transport.Subscribe(c.evict)
+		if err != nil {
+			return nil, err
+		}
+		c.unsubscribe = unsubscribe
+	}
+
+	return c, nil
+}
+
+// Close stops receiving invalidations from other nodes.
+func (c *CachedStore) Close() {
+	if c.unsubscribe != nil {
+		c.unsubscribe()
+	}
+}
+
+// cacheKey returns the key a session is cached and broadcast under.
+func cacheKey(sessionID string) string {
+	return sha256Hex(sessionID)
+}
+
+// get returns a fresh cached copy of a session.
+func (c *CachedStore) get(sessionID string) (*SessionData, bool) {
+	c.mutex.RLock()
+	entry, ok := c.entries[cacheKey(sessionID)]
+	c.mutex.RUnlock()
+
+	now := time.Now()
+	if !ok || now.Sub(entry.cachedAt) >= c.ttl || entry.session.ExpiresAt.Before(now) {
+		return nil, false
+	}
+	return entry.session.clone(), true
+}
+
+func (c *CachedStore) put(session *SessionData) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[cacheKey(session.ID)] = cacheEntry{session: session.clone(), cachedAt: time.Now()}
+}
+
+// evict drops the session cached under key.
+func (c *CachedStore) evict(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.entries, key)
+}
+
+// invalidate evicts a session on this node and announces it to the others.
+func (c *CachedStore) invalidate(sessionID string) error {
+	key := cacheKey(sessionID)
+	c.evict(key)
+
+	if c.transport == nil {
+		return nil
+	}
+	return c.transport.Publish(key)
+}
+
+func (c *CachedStore) CreateSession(userID string, duration time.Duration) (*SessionData, error) {
+	session, _, err := c.createSession(c.store, userID, duration)
+	return session, err
+}
+
+func (c *CachedStore) CreateSessionEvicting(userID string, duration time.Duration) (*SessionData, []*SessionData, error) {
+	return c.createSession(c.store, userID, duration)
+}
+
+// createSession revokes the sessions the store evicted for the session limit
+// on every node, as DeleteSession does. The new session exists by then, so
+// failing to publish an eviction does not fail the call; like any lost
+// message, it leaves the session cached on other nodes until the TTL runs out.
+func (c *CachedStore) createSession(store SessionStore, userID string, duration time.Duration) (*SessionData, []*SessionData, error) {
+	session, evicted, err := createSessionEvicting(store, userID, duration)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, old := range evicted {
+		_ = c.invalidate(old.ID)
+	}
+	c.put(session)
+	return session, evicted, nil
+}
+
+func (c *CachedStore) GetSession(sessionID string) (*SessionData, error) {
+	return c.getSession(allTenants, c.store, sessionID)
+}
+
+func (c *CachedStore) getSession(scope tenantScope, store SessionStore, sessionID string) (*SessionData, error) {
+	if session, ok := c.get(sessionID); ok && scope.allows(session) {
+		return session, nil
+	}
+
+	session, err := store.GetSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	c.put(session)
+	return session, nil
+}
+
+// UpdateSession writes through to the store and evicts the session on every
+// node, so no node keeps serving the old data.
+func (c *CachedStore) UpdateSession(session *SessionData) error {
+	return c.updateSession(c.store, session)
+}
+
+func (c *CachedStore) updateSession(store SessionStore, session *SessionData) error {
+	if err := updateSession(store, session); err != nil {
+		return err
+	}
+	return c.invalidate(session.ID)
+}
+
+// TouchSession writes through to the store. Activity is not broadcast; other
+// nodes pick it up when their entry runs out.
+func (c *CachedStore) TouchSession(sessionID string, activity SessionActivity) error {
+	return c.touchSession(c.store, sessionID, activity)
+}
+
+func (c *CachedStore) touchSession(store SessionStore, sessionID string, activity SessionActivity) error {
+	if err := touchSession(store, sessionID, activity); err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	// Readers clone cached sessions without holding the lock, so the cached
+	// session is replaced rather than modified.
+	key := cacheKey(sessionID)
+	if entry, ok := c.entries[key]; ok {
+		touched := entry.session.clone()
+		touched.LastSeenAt = activity.SeenAt
+		if activity.IP != "" {
+			touched.LastIP = activity.IP
+		}
+		c.entries[key] = cacheEntry{session: touched, cachedAt: entry.cachedAt}
+	}
+	return nil
+}
+
+// DeleteSession revokes the session in the store and on every node.
+func (c *CachedStore) DeleteSession(sessionID string) error {
+	return c.deleteSession(c.store, sessionID)
+}
+
+func (c *CachedStore) deleteSession(store SessionStore, sessionID string) error {
+	if err := store.DeleteSession(sessionID); err != nil {
+		return err
+	}
+	return c.invalidate(sessionID)
+}
+
+func (c *CachedStore) CleanupExpiredSessions() error {
+	_, err := c.purgeExpiredSessions(c.store)
+	return err
+}
+
+// PurgeExpiredSessions removes expired sessions from the store and the
+// cache. Other nodes never serve expired sessions from their caches, so
+// nothing is broadcast.
+func (c *CachedStore) PurgeExpiredSessions() ([]*SessionData, error) {
+	return c.purgeExpiredSessions(c.store)
+}
+
+func (c *CachedStore) purgeExpiredSessions(store SessionStore) ([]*SessionData, error) {
+	purger, ok := store.(ExpiredSessionPurger)
+	if !ok {
+		return nil, store.CleanupExpiredSessions()
+	}
+
+	purged, err := purger.PurgeExpiredSessions()
+	for _, session := range purged {
+		c.evict(cacheKey(session.ID))
+	}
+	return purged, err
+}
+
+func (c *CachedStore) CountActiveSessions() (int, error) {
+	return countActiveSessions(c.store)
+}
+
+// ListSessions lists the sessions of the wrapped store, bypassing the cache.
+func (c *CachedStore) ListSessions(query SessionQuery) ([]*SessionData, error) {
+	return listSessions(c.store, query)
+}
+
+// ImportSession imports into the wrapped store and evicts any session with
+// the same ID on every node.
+func (c *CachedStore) ImportSession(session *SessionData) error {
+	return c.importSession(c.store, session)
+}
+
+func (c *CachedStore) importSession(store SessionStore, session *SessionData) error {
+	if err := importSession(store, session); err != nil {
+		return err
+	}
+	return c.invalidate(session.ID)
+}
+
+// ForTenant returns a view of the cache in front of the tenant view of the
+// wrapped store. Cached sessions of other tenants are not served through it.
+func (c *CachedStore) ForTenant(tenant string) SessionStore {
+	store := c.store
+	if scoper, ok := store.(TenantScoper); ok {
+		store = scoper.ForTenant(tenant)
+	}
+	return &cachedTenantStore{cache: c, store: store, scope: tenantScope{tenant: tenant, scoped: true}}
+}
+
+// cachedTenantStore is the tenant-scoped view returned by CachedStore.ForTenant.
+type cachedTenantStore struct {
+	cache *CachedStore
+	store SessionStore
+	scope tenantScope
+}
+
+func (t *cachedTenantStore) CreateSession(userID string, duration time.Duration) (*SessionData, error) {
+	session, _, err := t.cache.createSession(t.store, userID, duration)
+	return session, err
+}
+
+func (t *cachedTenantStore) CreateSessionEvicting(userID string, duration time.Duration) (*SessionData, []*SessionData, error) {
+	return t.cache.createSession(t.store, userID, duration)
+}
+
+func (t *cachedTenantStore) GetSession(sessionID string) (*SessionData, error) {
+	return t.cache.getSession(t.scope, t.store, sessionID)
+}
+
+func (t *cachedTenantStore) UpdateSession(session *SessionData) error {
+	return t.cache.updateSession(t.store, session)
+}
+
+func (t *cachedTenantStore) TouchSession(sessionID string, activity SessionActivity) error {
+	return t.cache.touchSession(t.store, sessionID, activity)
+}
+
+func (t *cachedTenantStore) DeleteSession(sessionID string) error {
+	return t.cache.deleteSession(t.store, sessionID)
+}
+
+func (t *cachedTenantStore) CleanupExpiredSessions() error {
+	_, err := t.cache.purgeExpiredSessions(t.store)
+	return err
+}
+
+func (t *cachedTenantStore) PurgeExpiredSessions() ([]*SessionData, error) {
+	return t.cache.purgeExpiredSessions(t.store)
+}
+
+func (t *cachedTenantStore) CountActiveSessions() (int, error) {
+	return countActiveSessions(t.store)
+}
+
+func (t *cachedTenantStore) ListSessions(query SessionQuery) ([]*SessionData, error) {
+	return listSessions(t.store, query)
+}
+
+func (t *cachedTenantStore) ImportSession(session *SessionData) error {
+	return t.cache.importSession(t.store, session)
+}
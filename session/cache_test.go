@@ -0,0 +1,246 @@
+package session
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newCachedNodes returns two caches in front of store sharing one transport,
+// as two nodes of a deployment would.
+func newCachedNodes(t *testing.T, store SessionStore) (*CachedStore, *CachedStore) {
+	t.Helper()
+
+	transport := NewInProcessTransport()
+	a, err := NewCachedStore(store, time.Minute, transport)
+	if err != nil {
+		t.Fatalf("NewCachedStore() error = %v", err)
+	}
+	b, err := NewCachedStore(store, time.Minute, transport)
+	if err != nil {
+		t.Fatalf("NewCachedStore() error = %v", err)
+	}
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return a, b
+}
+
+func TestCachedStore_Revocation(t *testing.T) {
+	forEachStore(t, func(t *testing.T, backing SessionStore) {
+		a, b := newCachedNodes(t, backing)
+
+		// Node b serves cached sessions without asking the store.
+		stale, _ := a.CreateSession("user1", time.Hour)
+		if _, err := b.GetSession(stale.ID); err != nil {
+			t.Fatalf("GetSession() error = %v", err)
+		}
+		_ = backing.DeleteSession(stale.ID)
+		if _, err := b.GetSession(stale.ID); err != nil {
+			t.Errorf("expected cache hit after deleting behind the cache, got %v", err)
+		}
+
+		// Revoking on node a evicts the session on node b.
+		revoked, _ := a.CreateSession("user1", time.Hour)
+		if _, err := b.GetSession(revoked.ID); err != nil {
+			t.Fatalf("GetSession() error = %v", err)
+		}
+		if err := a.DeleteSession(revoked.ID); err != nil {
+			t.Fatalf("DeleteSession() error = %v", err)
+		}
+		if _, err := b.GetSession(revoked.ID); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("expected ErrSessionNotFound on the other node, got %v", err)
+		}
+
+		// Updates on node a are visible on node b.
+		updated, _ := a.CreateSession("user1", time.Hour)
+		_, _ = b.GetSession(updated.ID)
+		updated.Device = "Work laptop"
+		if err := a.UpdateSession(updated); err != nil {
+			t.Fatalf("UpdateSession() error = %v", err)
+		}
+		if got, _ := b.GetSession(updated.ID); got == nil || got.Device != "Work laptop" {
+			t.Errorf("expected the update on the other node, got %+v", got)
+		}
+	})
+}
+
+func TestCachedStore_LimitEvictions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, backing SessionStore) {
+		backing.(limitedStore).setLimit(SessionLimit{MaxSessions: 1})
+		a, b := newCachedNodes(t, backing)
+
+		first, _ := a.CreateSession("user1", time.Hour)
+		if _, err := b.GetSession(first.ID); err != nil {
+			t.Fatalf("GetSession() error = %v", err)
+		}
+
+		// The limit evicts the first session in the store, which revokes it
+		// on every node.
+		if _, err := a.CreateSession("user1", time.Hour); err != nil {
+			t.Fatalf("CreateSession() error = %v", err)
+		}
+		for name, node := range map[string]*CachedStore{"a": a, "b": b} {
+			if _, err := node.GetSession(first.ID); !errors.Is(err, ErrSessionNotFound) {
+				t.Errorf("node %s: expected ErrSessionNotFound for the evicted session, got %v", name, err)
+			}
+		}
+	})
+}
+
+func TestCachedStore_Expiry(t *testing.T) {
+	backing := NewInMemorySessionStore()
+	cache, err := NewCachedStore(backing, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("NewCachedStore() error = %v", err)
+	}
+
+	expired, _ := cache.CreateSession("user1", -time.Minute)
+	if _, err := cache.GetSession(expired.ID); !errors.Is(err, ErrSessionExpired) {
+		t.Errorf("expected ErrSessionExpired, got %v", err)
+	}
+
+	purged, err := cache.PurgeExpiredSessions()
+	if err != nil {
+		t.Fatalf("PurgeExpiredSessions() error = %v", err)
+	}
+	if len(purged) != 1 {
+		t.Errorf("expected 1 purged session, got %d", len(purged))
+	}
+	if len(cache.entries) != 0 {
+		t.Errorf("expected purged sessions to be evicted, %d cached", len(cache.entries))
+	}
+}
+
+func TestCachedStore_ForTenant(t *testing.T) {
+	cache, err := NewCachedStore(NewInMemorySessionStore(), time.Minute, nil)
+	if err != nil {
+		t.Fatalf("NewCachedStore() error = %v", err)
+	}
+
+	acme, _ := cache.ForTenant("acme").CreateSession("user1", time.Hour)
+	if acme.Tenant != "acme" {
+		t.Errorf("expected tenant acme, got %q", acme.Tenant)
+	}
+	if _, err := cache.ForTenant("globex").GetSession(acme.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected cached session hidden from other tenants, got %v", err)
+	}
+	if _, err := cache.ForTenant("acme").GetSession(acme.ID); err != nil {
+		t.Errorf("GetSession() error = %v", err)
+	}
+}
+
+func TestCachedStore_ConcurrentTouch(t *testing.T) {
+	cache, err := NewCachedStore(NewInMemorySessionStore(), time.Minute, nil)
+	if err != nil {
+		t.Fatalf("NewCachedStore() error = %v", err)
+	}
+
+	created, _ := cache.CreateSession("user1", time.Hour)
+	if _, err := cache.GetSession(created.ID); err != nil {
+		t.Fatalf("GetSession() error = %v", err)
+	}
+
+	// Run with -race: touching a cached session must not modify the copy
+	// that concurrent lookups clone.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = cache.TouchSession(created.ID, SessionActivity{SeenAt: time.Now(), IP: "192.0.2.1"})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, _ = cache.GetSession(created.ID)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := cache.GetSession(created.ID)
+	if err != nil {
+		t.Fatalf("GetSession() error = %v", err)
+	}
+	if got.LastIP != "192.0.2.1" {
+		t.Errorf("expected the touched IP in the cache, got %q", got.LastIP)
+	}
+}
+
+func TestParseRevocation(t *testing.T) {
+	key := cacheKey("session-id")
+
+	tests := []struct {
+		name    string
+		message string
+		wantOK  bool
+	}{
+		{"valid", revocationPrefix + key, true},
+		{"missing prefix", key, false},
+		{"other version", "sessions/v2 revoke " + key, false},
+		{"raw session id", revocationPrefix + "abcdefghijklmnopqrstuvwxyz012345", false},
+		{"not hex", revocationPrefix + strings.Repeat("z", 64), false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRevocation(tt.message)
+			if ok != tt.wantOK {
+				t.Fatalf("parseRevocation() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && got != key {
+				t.Errorf("parseRevocation() = %q, want %q", got, key)
+			}
+		})
+	}
+}
+
+func TestUDPTransport(t *testing.T) {
+	if _, err := NewUDPTransport("127.0.0.1:47001"); err == nil {
+		t.Error("expected an error for a unicast address")
+	}
+
+	transport, err := NewUDPTransport("239.255.43.21:47001")
+	if err != nil {
+		t.Skipf("multicast unavailable: %v", err)
+	}
+	defer transport.Close()
+
+	received := make(chan string, 1)
+	unsubscribe, err := transport.Subscribe(func(key string) {
+		select {
+		case received <- key:
+		default:
+		}
+	})
+	if err != nil {
+		t.Skipf("multicast unavailable: %v", err)
+	}
+	defer unsubscribe()
+
+	key := cacheKey("session-id")
+	deadline := time.After(2 * time.Second)
+	for {
+		// Datagrams sent before the group is joined are lost, so retry.
+		if err := transport.Publish(key); err != nil {
+			t.Skipf("multicast unavailable: %v", err)
+		}
+		select {
+		case got := <-received:
+			if got != key {
+				t.Errorf("received %q, want %q", got, key)
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		case <-deadline:
+			t.Skip("no multicast loopback on this host")
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		trusted      []netip.Prefix
+		wantIP       string
+		wantOK       bool
+	}{
+		{"direct client", "203.0.113.7:1234", nil, trusted, "203.0.113.7", true},
+		{"untrusted peer ignores header", "203.0.113.7:1234", []string{"198.51.100.1"}, trusted, "203.0.113.7", true},
+		{"no trusted proxies ignores header", "10.0.0.1:1234", []string{"198.51.100.1"}, nil, "10.0.0.1", true},
+		{"trusted proxy", "10.0.0.1:1234", []string{"198.51.100.1"}, trusted, "198.51.100.1", true},
+		{"chain of trusted proxies", "10.0.0.1:1234", []string{"198.51.100.1, 10.0.0.2"}, trusted, "198.51.100.1", true},
+		{"spoofed leftmost entry", "10.0.0.1:1234", []string{"192.0.2.1, 198.51.100.1"}, trusted, "198.51.100.1", true},
+		{"multiple headers", "10.0.0.1:1234", []string{"198.51.100.1", "10.0.0.2"}, trusted, "198.51.100.1", true},
+		{"ipv6 client", "[2001:db8::1]:1234", nil, trusted, "2001:db8::1", true},
+		{"invalid remote address", "not-an-ip", nil, trusted, "invalid IP", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+
+			ip, ok := ClientIP(req, tt.trusted)
+			if ok != tt.wantOK {
+				t.Fatalf("ClientIP() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ip.String() != tt.wantIP {
+				t.Errorf("ClientIP() = %v, want %v", ip, tt.wantIP)
+			}
+		})
+	}
+}
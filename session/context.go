@@ -0,0 +1,75 @@
+package session
+
+import (
+	"context"
+	"sync"
+)
+
+// contextKey is unexported so that no other package can collide with the
+// values stored by this one.
+type contextKey int
+
+const (
+	sessionContextKey contextKey = iota
+	tenantContextKey
+)
+
+// lazySession defers loading the session until a handler asks for it.
+type lazySession struct {
+	once    sync.Once
+	load    func() (*SessionData, error)
+	session *SessionData
+}
+
+// get loads the session on the first call and returns the cached result afterwards.
+func (l *lazySession) get() (*SessionData, bool) {
+	l.once.Do(func() {
+		session, err := l.load()
+		if err == nil {
+			l.session = session
+		}
+	})
+	return l.session, l.session != nil
+}
+
+// WithSession attaches a session to a context
+func WithSession(ctx context.Context, session *SessionData) context.Context {
+	return context.WithValue(ctx, sessionContextKey, session)
+}
+
+// withLazySession attaches a session loader to a context. The loader runs at
+// most once, the first time the session is retrieved.
+func withLazySession(ctx context.Context, load func() (*SessionData, error)) context.Context {
+	return context.WithValue(ctx, sessionContextKey, &lazySession{load: load})
+}
+
+// GetSessionFromContext retrieves a session from a context
+func GetSessionFromContext(ctx context.Context) (*SessionData, bool) {
+	switch value := ctx.Value(sessionContextKey).(type) {
+	case *SessionData:
+		return value, value != nil
+	case *lazySession:
+		return value.get()
+	default:
+		return nil, false
+	}
+}
+
+// MustSessionFromContext retrieves a session from a context and panics if
+// there is none. Use it only behind RequireSession or ValidateSession.
+func MustSessionFromContext(ctx context.Context) *SessionData {
+	session, ok := GetSessionFromContext(ctx)
+	if !ok {
+		panic("session: no session in context")
+	}
+	return session
+}
+
+// UserIDFromContext returns the user ID of the session in a context.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	session, ok := GetSessionFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	return session.UserID, true
+}
@@ -0,0 +1,101 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetSessionFromContext(t *testing.T) {
+	sessionData := &SessionData{ID: "session-1", UserID: "user1"}
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		wantOK     bool
+		wantUserID string
+	}{
+		{"session attached", WithSession(context.Background(), sessionData), true, "user1"},
+		{"no session", context.Background(), false, ""},
+		{"string key is not used", context.WithValue(context.Background(), CookieName, sessionData), false, ""},
+		{"lazy session", withLazySession(context.Background(), func() (*SessionData, error) {
+			return sessionData, nil
+		}), true, "user1"},
+		{"lazy session failing", withLazySession(context.Background(), func() (*SessionData, error) {
+			return nil, errors.New("invalid session")
+		}), false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok := GetSessionFromContext(tt.ctx)
+			if ok != tt.wantOK {
+				t.Errorf("GetSessionFromContext() ok = %v, want %v", ok, tt.wantOK)
+			}
+
+			userID, ok := UserIDFromContext(tt.ctx)
+			if ok != tt.wantOK || userID != tt.wantUserID {
+				t.Errorf("UserIDFromContext() = %q, %v, want %q, %v", userID, ok, tt.wantUserID, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMustSessionFromContext(t *testing.T) {
+	sessionData := &SessionData{ID: "session-1"}
+	if got := MustSessionFromContext(WithSession(context.Background(), sessionData)); got != sessionData {
+		t.Errorf("MustSessionFromContext() = %v, want %v", got, sessionData)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected MustSessionFromContext() to panic without a session")
+		}
+	}()
+	MustSessionFromContext(context.Background())
+}
+
+func TestSession_LazyLoadSession(t *testing.T) {
+	tests := []struct {
+		name        string
+		lookups     int
+		expectCalls int
+	}{
+		{"session never requested", 0, 0},
+		{"session requested once", 1, 1},
+		{"session requested repeatedly", 3, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			s := &Session{
+				Store: &MockSessionStore{
+					GetSessionFunc: func(sessionID string) (*SessionData, error) {
+						calls++
+						return &SessionData{ID: sessionID, ExpiresAt: time.Now().Add(time.Minute)}, nil
+					},
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(&http.Cookie{Name: CookieName, Value: "valid-session"})
+
+			handler := s.LazyLoadSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for i := 0; i < tt.lookups; i++ {
+					if _, ok := GetSessionFromContext(r.Context()); !ok {
+						t.Error("expected session in context")
+					}
+				}
+			}))
+
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if calls != tt.expectCalls {
+				t.Errorf("expected %d store calls, got %d", tt.expectCalls, calls)
+			}
+		})
+	}
+}
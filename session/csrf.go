@@ -0,0 +1,204 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+const (
+	// DefaultCSRFHeader is the header CSRF tokens are issued and accepted in.
+	DefaultCSRFHeader = "X-CSRF-Token"
+	// DefaultCSRFField is the form field CSRF tokens are accepted in.
+	DefaultCSRFField = "csrf_token"
+)
+
+const (
+	csrfSecretKey     = "_csrf_secret"
+	csrfSecretLength  = 32
+	csrfFailedMessage = "Invalid CSRF token"
+	csrfErrorMessage  = "Could not issue CSRF token"
+)
+
+// CSRF protects unsafe requests against cross-site request forgery using a
+// secret stored in the session. It must be placed behind a middleware that
+// attaches the session to the request context, such as LoadSession.
+type CSRF struct {
+	// Store persists the secret when a session does not have one yet. It
+	// must implement SessionUpdater.
+	Store SessionStore
+
+	// HeaderName defaults to DefaultCSRFHeader.
+	HeaderName string
+	// FieldName defaults to DefaultCSRFField.
+	FieldName string
+
+	// FailureHandler is called when a request fails the check. It defaults to
+	// a 403 Forbidden response.
+	FailureHandler http.Handler
+
+	// ExemptPaths lists request paths that are never checked.
+	ExemptPaths []string
+	// Exempt reports whether a request should skip the check.
+	Exempt func(r *http.Request) bool
+}
+
+// Protect makes sure every session has a CSRF secret, exposes a masked token
+// in the response header and rejects unsafe requests without a valid token.
+func (c *CSRF) Protect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var secret []byte
+		if sessionData, ok := GetSessionFromContext(r.Context()); ok {
+			var err error
+			secret, err = c.ensureSecret(sessionData)
+			if err != nil {
+				http.Error(w, csrfErrorMessage, http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set(c.headerName(), maskCSRFSecret(secret))
+		}
+
+		if isSafeMethod(r.Method) || c.isExempt(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if secret == nil || !validCSRFToken(secret, c.requestToken(r)) {
+			c.fail(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CSRFToken returns a freshly masked CSRF token for the session of the
+// request, for use in templates. It is empty when the request passed through
+// no CSRF middleware or carries no session.
+func CSRFToken(r *http.Request) string {
+	sessionData, ok := GetSessionFromContext(r.Context())
+	if !ok {
+		return ""
+	}
+
+	secret, ok := csrfSecret(sessionData)
+	if !ok {
+		return ""
+	}
+	return maskCSRFSecret(secret)
+}
+
+// ensureSecret returns the CSRF secret of a session, generating and storing
+// one if needed.
+func (c *CSRF) ensureSecret(sessionData *SessionData) ([]byte, error) {
+	if secret, ok := csrfSecret(sessionData); ok {
+		return secret, nil
+	}
+
+	secret := make([]byte, csrfSecretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	if sessionData.Values == nil {
+		sessionData.Values = make(map[string]string)
+	}
+	sessionData.Values[csrfSecretKey] = base64.RawURLEncoding.EncodeToString(secret)
+
+	if err := updateSession(c.Store, sessionData); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// requestToken reads the token from the header or, failing that, the form.
+func (c *CSRF) requestToken(r *http.Request) string {
+	if token := r.Header.Get(c.headerName()); token != "" {
+		return token
+	}
+
+	fieldName := c.FieldName
+	if fieldName == "" {
+		fieldName = DefaultCSRFField
+	}
+	return r.PostFormValue(fieldName)
+}
+
+func (c *CSRF) headerName() string {
+	if c.HeaderName == "" {
+		return DefaultCSRFHeader
+	}
+	return c.HeaderName
+}
+
+func (c *CSRF) isExempt(r *http.Request) bool {
+	for _, path := range c.ExemptPaths {
+		if r.URL.Path == path {
+			return true
+		}
+	}
+	return c.Exempt != nil && c.Exempt(r)
+}
+
+func (c *CSRF) fail(w http.ResponseWriter, r *http.Request) {
+	if c.FailureHandler != nil {
+		c.FailureHandler.ServeHTTP(w, r)
+		return
+	}
+	http.Error(w, csrfFailedMessage, http.StatusForbidden)
+}
+
+// csrfSecret decodes the CSRF secret stored in a session.
+func csrfSecret(sessionData *SessionData) ([]byte, bool) {
+	encoded, ok := sessionData.Values[csrfSecretKey]
+	if !ok {
+		return nil, false
+	}
+
+	secret, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(secret) != csrfSecretLength {
+		return nil, false
+	}
+	return secret, true
+}
+
+// maskCSRFSecret XORs the secret with a random one-time pad, so that the
+// token differs on every response and cannot be recovered through
+// compression side channels such as BREACH.
+func maskCSRFSecret(secret []byte) string {
+	token := make([]byte, 2*len(secret))
+	pad := token[:len(secret)]
+	_, _ = rand.Read(pad)
+
+	for i := range secret {
+		token[len(secret)+i] = secret[i] ^ pad[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(token)
+}
+
+// validCSRFToken unmasks a token and compares it with the secret in constant time.
+func validCSRFToken(secret []byte, token string) bool {
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(token))
+	if err != nil || len(decoded) != 2*len(secret) {
+		return false
+	}
+
+	pad, masked := decoded[:len(secret)], decoded[len(secret):]
+	unmasked := make([]byte, len(secret))
+	for i := range secret {
+		unmasked[i] = masked[i] ^ pad[i]
+	}
+	return subtle.ConstantTimeCompare(unmasked, secret) == 1
+}
+
+// isSafeMethod reports whether a method is considered safe by RFC 9110.
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
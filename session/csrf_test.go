@@ -0,0 +1,154 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSRF_Protect(t *testing.T) {
+	store := NewInMemorySessionStore()
+	sessionData, _ := store.CreateSession("user1", time.Hour)
+	otherSession, _ := store.CreateSession("user2", time.Hour)
+
+	csrf := &CSRF{Store: store, ExemptPaths: []string{"/webhook"}}
+
+	// Issue a token for each session through a safe request.
+	issue := func(session *SessionData) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(WithSession(req.Context(), session))
+		rr := httptest.NewRecorder()
+		csrf.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, req)
+		return rr.Header().Get(DefaultCSRFHeader)
+	}
+	token := issue(sessionData)
+	otherToken := issue(otherSession)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		session    *SessionData
+		header     string
+		form       string
+		expectCode int
+	}{
+		{"safe method without token", http.MethodGet, "/", sessionData, "", "", http.StatusOK},
+		{"token in header", http.MethodPost, "/", sessionData, token, "", http.StatusOK},
+		{"token in form", http.MethodPost, "/", sessionData, "", token, http.StatusOK},
+		{"missing token", http.MethodPost, "/", sessionData, "", "", http.StatusForbidden},
+		{"malformed token", http.MethodPost, "/", sessionData, "not-a-token", "", http.StatusForbidden},
+		{"token of another session", http.MethodPost, "/", sessionData, otherToken, "", http.StatusForbidden},
+		{"no session", http.MethodPost, "/", nil, token, "", http.StatusForbidden},
+		{"exempt path", http.MethodPost, "/webhook", sessionData, "", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body *strings.Reader
+			if tt.form != "" {
+				body = strings.NewReader(url.Values{DefaultCSRFField: {tt.form}}.Encode())
+			} else {
+				body = strings.NewReader("")
+			}
+
+			req := httptest.NewRequest(tt.method, tt.path, body)
+			if tt.form != "" {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			if tt.header != "" {
+				req.Header.Set(DefaultCSRFHeader, tt.header)
+			}
+			if tt.session != nil {
+				stored, _ := store.GetSession(tt.session.ID)
+				req = req.WithContext(WithSession(req.Context(), stored))
+			}
+
+			rr := httptest.NewRecorder()
+			csrf.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectCode {
+				t.Errorf("expected code %d, got %d", tt.expectCode, rr.Code)
+			}
+		})
+	}
+}
+
+func TestCSRF_FailureHandler(t *testing.T) {
+	store := NewInMemorySessionStore()
+	sessionData, _ := store.CreateSession("user1", time.Hour)
+
+	csrf := &CSRF{
+		Store: store,
+		FailureHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}),
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req = req.WithContext(WithSession(req.Context(), sessionData))
+	rr := httptest.NewRecorder()
+	csrf.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusTeapot {
+		t.Errorf("expected code %d, got %d", http.StatusTeapot, rr.Code)
+	}
+}
+
+func TestCSRF_StoreWithoutUpdates(t *testing.T) {
+	store := NewInMemorySessionStore()
+	sessionData, _ := store.CreateSession("user1", time.Hour)
+	csrf := &CSRF{Store: basicStore{store}}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(WithSession(req.Context(), sessionData.clone()))
+	rr := httptest.NewRecorder()
+	csrf.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected code %d, got %d", http.StatusInternalServerError, rr.Code)
+	}
+	if _, err := csrf.ensureSecret(sessionData); !errors.Is(err, ErrUpdateNotSupported) {
+		t.Errorf("expected ErrUpdateNotSupported, got %v", err)
+	}
+}
+
+func TestCSRFToken(t *testing.T) {
+	store := NewInMemorySessionStore()
+	sessionData, _ := store.CreateSession("user1", time.Hour)
+	csrf := &CSRF{Store: store}
+
+	var first, second string
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(WithSession(req.Context(), sessionData))
+	csrf.Protect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first = CSRFToken(r)
+		second = CSRFToken(r)
+	})).ServeHTTP(httptest.NewRecorder(), req)
+
+	if first == "" || second == "" {
+		t.Fatal("expected CSRF tokens to be issued")
+	}
+	if first == second {
+		t.Error("expected masked tokens to differ between calls")
+	}
+
+	stored, _ := store.GetSession(sessionData.ID)
+	secret, ok := csrfSecret(stored)
+	if !ok {
+		t.Fatal("expected CSRF secret to be persisted in the store")
+	}
+	if !validCSRFToken(secret, first) || !validCSRFToken(secret, second) {
+		t.Error("expected both tokens to validate against the stored secret")
+	}
+
+	if token := CSRFToken(httptest.NewRequest(http.MethodGet, "/", nil)); token != "" {
+		t.Errorf("expected empty token without session, got %q", token)
+	}
+}
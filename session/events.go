@@ -0,0 +1,341 @@
+package session
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// SessionEventType names the session lifecycle events published on an EventBus.
+type SessionEventType int
+
+const (
+	SessionCreated SessionEventType = iota
+	SessionDeleted
+	SessionExpired
+	SessionRotated
+)
+
+// String returns the name of the event type.
+func (t SessionEventType) String() string {
+	switch t {
+	case SessionCreated:
+		return "create"
+	case SessionDeleted:
+		return "delete"
+	case SessionExpired:
+		return "expire"
+	case SessionRotated:
+		return "rotate"
+	default:
+		return "unknown"
+	}
+}
+
+// SessionEvent describes a change to a session. Session is shared between
+// all handlers and must not be modified; it is nil when a session expired
+// on lookup, where only its ID is known.
+type SessionEvent struct {
+	Type      SessionEventType
+	Time      time.Time
+	SessionID string
+	Session   *SessionData
+	// PreviousID is the session replaced by a rotation.
+	PreviousID string
+	// Reason is set on deletions the store made on its own, such as
+	// ReasonSessionLimit.
+	Reason string
+}
+
+// ReasonSessionLimit is the Reason of deletions evicting a session to
+// enforce a SessionLimit.
+const ReasonSessionLimit = "session_limit"
+
+// EventHandler receives session events.
+type EventHandler func(event SessionEvent)
+
+// Delivery decides how events reach a handler.
+type Delivery int
+
+const (
+	// DeliverSync calls the handler before the store operation returns.
+	DeliverSync Delivery = iota
+	// DeliverAsync calls the handler on a goroutine of its own, in the order
+	// the events were published. Store operations never wait for it.
+	DeliverAsync
+)
+
+// EventBus publishes session lifecycle events to subscribers. Stores
+// publish creations, deletions and expiries when wrapped with WithEvents; a
+// Session publishes rotations when its Events field is set.
+type EventBus struct {
+	mutex       sync.RWMutex
+	subscribers map[SessionEventType][]*subscriber
+}
+
+// NewEventBus creates an event bus without subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[SessionEventType][]*subscriber)}
+}
+
+// OnCreate subscribes handler to new sessions.
+func (b *EventBus) OnCreate(delivery Delivery, handler EventHandler) (unsubscribe func()) {
+	return b.subscribe(SessionCreated, delivery, handler)
+}
+
+// OnDelete subscribes handler to revoked sessions.
+func (b *EventBus) OnDelete(delivery Delivery, handler EventHandler) (unsubscribe func()) {
+	return b.subscribe(SessionDeleted, delivery, handler)
+}
+
+// OnExpire subscribes handler to expired sessions, published once each: on
+// the lookup that finds and removes them, or by the cleanup removing them.
+func (b *EventBus) OnExpire(delivery Delivery, handler EventHandler) (unsubscribe func()) {
+	return b.subscribe(SessionExpired, delivery, handler)
+}
+
+// OnRotate subscribes handler to logins that replace a live session.
+func (b *EventBus) OnRotate(delivery Delivery, handler EventHandler) (unsubscribe func()) {
+	return b.subscribe(SessionRotated, delivery, handler)
+}
+
+// subscribe adds a subscriber. The returned function removes it; for
+// asynchronous subscribers it waits until queued events are delivered.
+func (b *EventBus) subscribe(eventType SessionEventType, delivery Delivery, handler EventHandler) func() {
+	sub := &subscriber{handler: handler}
+	if delivery == DeliverAsync {
+		sub.start()
+	}
+
+	b.mutex.Lock()
+	b.subscribers[eventType] = append(b.subscribers[eventType], sub)
+	b.mutex.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			subs := b.subscribers[eventType]
+			for i, s := range subs {
+				if s == sub {
+					b.subscribers[eventType] = append(subs[:i:i], subs[i+1:]...)
+					break
+				}
+			}
+			b.mutex.Unlock()
+
+			sub.stop()
+		})
+	}
+}
+
+// Close removes all subscribers, waiting for queued events to be delivered.
+func (b *EventBus) Close() {
+	b.mutex.Lock()
+	subscribers := b.subscribers
+	b.subscribers = make(map[SessionEventType][]*subscriber)
+	b.mutex.Unlock()
+
+	for _, subs := range subscribers {
+		for _, sub := range subs {
+			sub.stop()
+		}
+	}
+}
+
+// publish delivers an event to the subscribers of its type.
+func (b *EventBus) publish(event SessionEvent) {
+	if b == nil {
+		return
+	}
+
+	b.mutex.RLock()
+	subs := b.subscribers[event.Type]
+	b.mutex.RUnlock()
+
+	if len(subs) == 0 {
+		return
+	}
+
+	event.Time = time.Now()
+	if event.Session != nil {
+		event.Session = event.Session.clone()
+	}
+	for _, sub := range subs {
+		sub.deliver(event)
+	}
+}
+
+// subscriber is a handler subscribed to one event type. Asynchronous
+// subscribers queue events for a goroutine of their own.
+type subscriber struct {
+	handler EventHandler
+	async   bool
+
+	mutex  sync.Mutex
+	wake   *sync.Cond
+	queue  []SessionEvent
+	closed bool
+	done   chan struct{}
+}
+
+func (s *subscriber) start() {
+	s.async = true
+	s.wake = sync.NewCond(&s.mutex)
+	s.done = make(chan struct{})
+	go s.run()
+}
+
+func (s *subscriber) deliver(event SessionEvent) {
+	if !s.async {
+		s.handler(event)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.closed {
+		s.queue = append(s.queue, event)
+		s.wake.Signal()
+	}
+}
+
+// run delivers queued events until the subscriber is stopped and its queue
+// is empty.
+func (s *subscriber) run() {
+	defer close(s.done)
+
+	for {
+		s.mutex.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.wake.Wait()
+		}
+		events := s.queue
+		s.queue = nil
+		closed := s.closed
+		s.mutex.Unlock()
+
+		for _, event := range events {
+			s.handler(event)
+		}
+		if closed && len(events) == 0 {
+			return
+		}
+	}
+}
+
+func (s *subscriber) stop() {
+	if !s.async {
+		return
+	}
+
+	s.mutex.Lock()
+	s.closed = true
+	s.wake.Signal()
+	s.mutex.Unlock()
+
+	<-s.done
+}
+
+// WithEvents wraps a store so that it publishes creations, deletions and
+// expiries on bus.
+func WithEvents(store SessionStore, bus *EventBus) SessionStore {
+	return &eventStore{store: store, bus: bus}
+}
+
+// eventStore is the store returned by WithEvents.
+type eventStore struct {
+	store SessionStore
+	bus   *EventBus
+}
+
+func (s *eventStore) CreateSession(userID string, duration time.Duration) (*SessionData, error) {
+	session, _, err := s.CreateSessionEvicting(userID, duration)
+	return session, err
+}
+
+// CreateSessionEvicting also publishes the deletion of the sessions evicted
+// by the session limit of the wrapped store.
+func (s *eventStore) CreateSessionEvicting(userID string, duration time.Duration) (*SessionData, []*SessionData, error) {
+	session, evicted, err := createSessionEvicting(s.store, userID, duration)
+	for _, old := range evicted {
+		s.bus.publish(SessionEvent{Type: SessionDeleted, SessionID: old.ID, Session: old, Reason: ReasonSessionLimit})
+	}
+	if err == nil {
+		s.bus.publish(SessionEvent{Type: SessionCreated, SessionID: session.ID, Session: session})
+	}
+	return session, evicted, err
+}
+
+// GetSession removes a session it finds expired, so that its expiry is
+// published once rather than on every lookup until the next cleanup.
+func (s *eventStore) GetSession(sessionID string) (*SessionData, error) {
+	session, err := s.store.GetSession(sessionID)
+	if errors.Is(err, ErrSessionExpired) && s.store.DeleteSession(sessionID) == nil {
+		s.bus.publish(SessionEvent{Type: SessionExpired, SessionID: sessionID})
+	}
+	return session, err
+}
+
+func (s *eventStore) UpdateSession(session *SessionData) error {
+	return updateSession(s.store, session)
+}
+
+func (s *eventStore) TouchSession(sessionID string, activity SessionActivity) error {
+	return touchSession(s.store, sessionID, activity)
+}
+
+// DeleteSession looks the session up first, so that handlers learn whose
+// session was revoked and deleting an unknown session publishes nothing.
+func (s *eventStore) DeleteSession(sessionID string) error {
+	session, lookupErr := s.store.GetSession(sessionID)
+
+	if err := s.store.DeleteSession(sessionID); err != nil {
+		return err
+	}
+
+	if lookupErr == nil {
+		s.bus.publish(SessionEvent{Type: SessionDeleted, SessionID: sessionID, Session: session})
+	}
+	return nil
+}
+
+func (s *eventStore) CleanupExpiredSessions() error {
+	_, err := s.PurgeExpiredSessions()
+	return err
+}
+
+// PurgeExpiredSessions publishes an expiry for every purged session when
+// the wrapped store implements ExpiredSessionPurger.
+func (s *eventStore) PurgeExpiredSessions() ([]*SessionData, error) {
+	purger, ok := s.store.(ExpiredSessionPurger)
+	if !ok {
+		return nil, s.store.CleanupExpiredSessions()
+	}
+
+	purged, err := purger.PurgeExpiredSessions()
+	for _, session := range purged {
+		s.bus.publish(SessionEvent{Type: SessionExpired, SessionID: session.ID, Session: session})
+	}
+	return purged, err
+}
+
+func (s *eventStore) CountActiveSessions() (int, error) {
+	return countActiveSessions(s.store)
+}
+
+func (s *eventStore) ListSessions(query SessionQuery) ([]*SessionData, error) {
+	return listSessions(s.store, query)
+}
+
+func (s *eventStore) ImportSession(session *SessionData) error {
+	return importSession(s.store, session)
+}
+
+// ForTenant publishes the events of the tenant view of the wrapped store.
+func (s *eventStore) ForTenant(tenant string) SessionStore {
+	if scoper, ok := s.store.(TenantScoper); ok {
+		return &eventStore{store: scoper.ForTenant(tenant), bus: s.bus}
+	}
+	return s
+}
@@ -0,0 +1,206 @@
+package session
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventLog collects events delivered to handlers.
+type eventLog struct {
+	mutex  sync.Mutex
+	events []SessionEvent
+}
+
+func (l *eventLog) handle(event SessionEvent) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *eventLog) types() []SessionEventType {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	types := make([]SessionEventType, len(l.events))
+	for i, event := range l.events {
+		types[i] = event.Type
+	}
+	return types
+}
+
+func subscribeAll(bus *EventBus, delivery Delivery, log *eventLog) {
+	bus.OnCreate(delivery, log.handle)
+	bus.OnDelete(delivery, log.handle)
+	bus.OnExpire(delivery, log.handle)
+	bus.OnRotate(delivery, log.handle)
+}
+
+func TestWithEvents(t *testing.T) {
+	forEachStore(t, func(t *testing.T, backing SessionStore) {
+		bus := NewEventBus()
+		log := &eventLog{}
+		subscribeAll(bus, DeliverSync, log)
+		store := WithEvents(backing, bus)
+
+		created, _ := store.CreateSession("user1", time.Hour)
+		expired, _ := store.CreateSession("user2", -time.Minute)
+		_ = store.DeleteSession(created.ID)
+		_ = store.DeleteSession("unknown")
+
+		removed, err := (&Janitor{Store: store}).Sweep()
+		if err != nil {
+			t.Fatalf("Sweep() error = %v", err)
+		}
+		if removed != 1 {
+			t.Errorf("expected 1 session removed, got %d", removed)
+		}
+
+		expect := []SessionEventType{SessionCreated, SessionCreated, SessionDeleted, SessionExpired}
+		if got := log.types(); !slices.Equal(got, expect) {
+			t.Fatalf("expected events %v, got %v", expect, got)
+		}
+
+		deleted, expiry := log.events[2], log.events[3]
+		if deleted.SessionID != created.ID || deleted.Session == nil || deleted.Session.UserID != "user1" {
+			t.Errorf("unexpected delete event %+v", deleted)
+		}
+		if expiry.SessionID != expired.ID || expiry.Session == nil || expiry.Session.UserID != "user2" {
+			t.Errorf("unexpected expire event %+v", expiry)
+		}
+	})
+}
+
+func TestWithEvents_ExpiryOnLookupPublishedOnce(t *testing.T) {
+	forEachStore(t, func(t *testing.T, backing SessionStore) {
+		bus := NewEventBus()
+		log := &eventLog{}
+		bus.OnExpire(DeliverSync, log.handle)
+		store := WithEvents(backing, bus)
+
+		expired, _ := store.CreateSession("user1", -time.Minute)
+		for i := 0; i < 3; i++ {
+			_, _ = store.GetSession(expired.ID)
+		}
+		_ = store.CleanupExpiredSessions()
+
+		if got := log.types(); len(got) != 1 || log.events[0].SessionID != expired.ID {
+			t.Errorf("expected a single expiry event, got %+v", log.events)
+		}
+	})
+}
+
+func TestWithEvents_LimitEvictions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, backing SessionStore) {
+		backing.(limitedStore).setLimit(SessionLimit{MaxSessions: 1})
+		bus := NewEventBus()
+		log := &eventLog{}
+		subscribeAll(bus, DeliverSync, log)
+		store := WithEvents(backing, bus)
+
+		first, _ := store.CreateSession("user1", time.Hour)
+		_, _ = store.CreateSession("user1", time.Hour)
+
+		expect := []SessionEventType{SessionCreated, SessionDeleted, SessionCreated}
+		if got := log.types(); !slices.Equal(got, expect) {
+			t.Fatalf("expected events %v, got %v", expect, got)
+		}
+		if evicted := log.events[1]; evicted.SessionID != first.ID || evicted.Reason != ReasonSessionLimit {
+			t.Errorf("unexpected eviction event %+v", evicted)
+		}
+	})
+}
+
+func TestEventBus_AsyncDelivery(t *testing.T) {
+	bus := NewEventBus()
+	log := &eventLog{}
+	release := make(chan struct{})
+	unsubscribe := bus.OnCreate(DeliverAsync, func(event SessionEvent) {
+		<-release
+		log.handle(event)
+	})
+
+	store := WithEvents(NewInMemorySessionStore(), bus)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		// The store must not wait for the blocked handler.
+		session, err := store.CreateSession("user1", time.Hour)
+		if err != nil {
+			t.Fatalf("CreateSession() error = %v", err)
+		}
+		ids = append(ids, session.ID)
+	}
+
+	close(release)
+	unsubscribe()
+
+	if len(log.events) != len(ids) {
+		t.Fatalf("expected %d events after unsubscribing, got %d", len(ids), len(log.events))
+	}
+	for i, event := range log.events {
+		if event.SessionID != ids[i] {
+			t.Errorf("event %d delivered out of order", i)
+		}
+	}
+
+	_, _ = store.CreateSession("user1", time.Hour)
+	if len(log.events) != len(ids) {
+		t.Error("expected no delivery after unsubscribing")
+	}
+}
+
+func TestSession_PublishesRotation(t *testing.T) {
+	bus := NewEventBus()
+	log := &eventLog{}
+	bus.OnRotate(DeliverSync, log.handle)
+	defer bus.Close()
+
+	store := NewInMemorySessionStore()
+	s := &Session{Store: WithEvents(store, bus), Events: bus}
+
+	first, _ := s.Start(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/login", nil), "user1", StartOptions{})
+
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.AddCookie(&http.Cookie{Name: CookieName, Value: first.ID})
+	second, _ := s.Start(httptest.NewRecorder(), req, "user1", StartOptions{})
+
+	if len(log.events) != 1 {
+		t.Fatalf("expected 1 rotation, got %d", len(log.events))
+	}
+	if event := log.events[0]; event.SessionID != second.ID || event.PreviousID != first.ID {
+		t.Errorf("unexpected rotation %+v", event)
+	}
+}
+
+func TestJanitor_Run(t *testing.T) {
+	store := NewInMemorySessionStore()
+	expired, _ := store.CreateSession("user1", -time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		(&Janitor{Store: store, Interval: time.Millisecond}).Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		store.mutex.RLock()
+		_, exists := store.sessions[expired.ID]
+		store.mutex.RUnlock()
+		if !exists {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected janitor to remove the expired session")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	<-done
+}
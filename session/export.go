@@ -0,0 +1,243 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Export format written by Export and read by Import: a header line
+// followed by one JSON object per session.
+const (
+	exportFormat  = "sessions"
+	exportVersion = 1
+)
+
+// exportPageSize is the number of sessions Export reads from the store at a time.
+const exportPageSize = 500
+
+// ExportOptions configures Export.
+type ExportOptions struct {
+	// SkipExpired leaves out sessions that have expired but were not
+	// cleaned up yet.
+	SkipExpired bool
+}
+
+// ImportOptions configures Import.
+type ImportOptions struct {
+	// SkipExpired leaves out sessions that expired by the time of the import.
+	SkipExpired bool
+
+	// RehashIDs gives every imported session a new ID, a keyed hash of its
+	// exported one, so that references between imported sessions such as
+	// ImpersonatorSessionID stay intact. Use it when the export may have been
+	// seen by others: the sessions keep their data, but the IDs in the export,
+	// and the cookies holding them, stop working. Users log in again, or are
+	// logged back in by their remember-me cookie.
+	RehashIDs bool
+}
+
+// idRehasher derives new session IDs from exported ones with a key that is
+// discarded after the import, so the new IDs cannot be computed from the
+// export.
+type idRehasher []byte
+
+func newIDRehasher() (idRehasher, error) {
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return idRehasher(key), nil
+}
+
+// rehash returns the new ID of the session exported as id.
+func (r idRehasher) rehash(id string) string {
+	mac := hmac.New(sha256.New, r)
+	mac.Write([]byte(id))
+	sum := mac.Sum(nil)
+
+	b := make([]byte, sessionIDLength)
+	for i := range b {
+		b[i] = sessionIDCharset[int(sum[i%len(sum)])%len(sessionIDCharset)]
+	}
+	return string(b)
+}
+
+// exportHeader is the first line of an export.
+type exportHeader struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+// exportRecord is a session as written to an export. Unlike the other JSON
+// representations of sessions it keeps every field, values included, so
+// that an imported session works exactly like the exported one.
+type exportRecord struct {
+	ID                    string            `json:"id"`
+	UserID                string            `json:"user_id"`
+	Tenant                string            `json:"tenant,omitempty"`
+	CreatedAt             time.Time         `json:"created_at"`
+	ExpiresAt             time.Time         `json:"expires_at"`
+	LastSeenAt            time.Time         `json:"last_seen_at"`
+	CreatedIP             string            `json:"created_ip,omitempty"`
+	LastIP                string            `json:"last_ip,omitempty"`
+	UserAgent             string            `json:"user_agent,omitempty"`
+	Device                string            `json:"device,omitempty"`
+	AuthLevel             AuthLevel         `json:"auth_level,omitempty"`
+	AuthenticatedAt       time.Time         `json:"authenticated_at"`
+	Roles                 []string          `json:"roles,omitempty"`
+	Scopes                []string          `json:"scopes,omitempty"`
+	RolesLoadedAt         time.Time         `json:"roles_loaded_at"`
+	ImpersonatorID        string            `json:"impersonator_id,omitempty"`
+	ImpersonatorSessionID string            `json:"impersonator_session_id,omitempty"`
+	UserAgentHash         string            `json:"ua_hash,omitempty"`
+	IPNetwork             string            `json:"ip_network,omitempty"`
+	Values                map[string]string `json:"values,omitempty"`
+}
+
+func newExportRecord(session *SessionData) exportRecord {
+	return exportRecord{
+		ID:                    session.ID,
+		UserID:                session.UserID,
+		Tenant:                session.Tenant,
+		CreatedAt:             session.CreatedAt,
+		ExpiresAt:             session.ExpiresAt,
+		LastSeenAt:            session.LastSeenAt,
+		CreatedIP:             session.CreatedIP,
+		LastIP:                session.LastIP,
+		UserAgent:             session.UserAgent,
+		Device:                session.Device,
+		AuthLevel:             session.AuthLevel,
+		AuthenticatedAt:       session.AuthenticatedAt,
+		Roles:                 session.Roles,
+		Scopes:                session.Scopes,
+		RolesLoadedAt:         session.RolesLoadedAt,
+		ImpersonatorID:        session.ImpersonatorID,
+		ImpersonatorSessionID: session.ImpersonatorSessionID,
+		UserAgentHash:         session.UserAgentHash,
+		IPNetwork:             session.IPNetwork,
+		Values:                session.Values,
+	}
+}
+
+func (r exportRecord) session() *SessionData {
+	return &SessionData{
+		ID:                    r.ID,
+		UserID:                r.UserID,
+		Tenant:                r.Tenant,
+		CreatedAt:             r.CreatedAt,
+		ExpiresAt:             r.ExpiresAt,
+		LastSeenAt:            r.LastSeenAt,
+		CreatedIP:             r.CreatedIP,
+		LastIP:                r.LastIP,
+		UserAgent:             r.UserAgent,
+		Device:                r.Device,
+		AuthLevel:             r.AuthLevel,
+		AuthenticatedAt:       r.AuthenticatedAt,
+		Roles:                 r.Roles,
+		Scopes:                r.Scopes,
+		RolesLoadedAt:         r.RolesLoadedAt,
+		ImpersonatorID:        r.ImpersonatorID,
+		ImpersonatorSessionID: r.ImpersonatorSessionID,
+		UserAgentHash:         r.UserAgentHash,
+		IPNetwork:             r.IPNetwork,
+		Values:                r.Values,
+	}
+}
+
+// Export writes the sessions of store to w as JSON lines and returns the
+// number of sessions written. Sessions are read a page at a time, so the
+// store is never loaded into memory as a whole. The store must implement
+// SessionLister.
+func Export(w io.Writer, store SessionStore, opts ExportOptions) (int, error) {
+	encoder := json.NewEncoder(w)
+	header := exportHeader{Format: exportFormat, Version: exportVersion, ExportedAt: time.Now()}
+	if err := encoder.Encode(header); err != nil {
+		return 0, err
+	}
+
+	query := SessionQuery{Limit: exportPageSize, IncludeExpired: !opts.SkipExpired}
+	exported := 0
+	for {
+		page, err := listSessions(store, query)
+		if err != nil {
+			return exported, err
+		}
+
+		for _, session := range page {
+			if err := encoder.Encode(newExportRecord(session)); err != nil {
+				return exported, err
+			}
+			exported++
+		}
+
+		if len(page) < query.Limit {
+			return exported, nil
+		}
+		query.After = page[len(page)-1].ID
+	}
+}
+
+// Import reads an export written by Export from r into store and returns
+// the number of sessions imported. Sessions keep their IDs, so users stay
+// logged in. Sessions with the ID of an existing session replace it. The
+// store must implement SessionImporter.
+func Import(r io.Reader, store SessionStore, opts ImportOptions) (int, error) {
+	decoder := json.NewDecoder(r)
+
+	var header exportHeader
+	if err := decoder.Decode(&header); err != nil {
+		return 0, fmt.Errorf("reading export header: %w", err)
+	}
+	if header.Format != exportFormat {
+		return 0, errors.New("not a session export")
+	}
+	if header.Version != exportVersion {
+		return 0, fmt.Errorf("unsupported export version %d", header.Version)
+	}
+
+	var rehasher idRehasher
+	if opts.RehashIDs {
+		var err error
+		if rehasher, err = newIDRehasher(); err != nil {
+			return 0, err
+		}
+	}
+
+	imported := 0
+	for line := 2; ; line++ {
+		var record exportRecord
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return imported, nil
+		}
+		if err != nil {
+			return imported, fmt.Errorf("reading line %d: %w", line, err)
+		}
+
+		if record.ID == "" || record.UserID == "" {
+			return imported, fmt.Errorf("session on line %d has no ID or user", line)
+		}
+		if opts.SkipExpired && record.ExpiresAt.Before(time.Now()) {
+			continue
+		}
+
+		session := record.session()
+		if rehasher != nil {
+			session.ID = rehasher.rehash(session.ID)
+			if session.ImpersonatorSessionID != "" {
+				session.ImpersonatorSessionID = rehasher.rehash(session.ImpersonatorSessionID)
+			}
+		}
+		if err := importSession(store, session); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+}
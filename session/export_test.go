@@ -0,0 +1,155 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportImport(t *testing.T) {
+	source := NewInMemorySessionStore()
+	admin, _ := source.CreateSession("admin", time.Hour)
+	full := &SessionData{
+		ID:                    generateSessionID(),
+		UserID:                "user1",
+		Tenant:                "acme",
+		CreatedAt:             time.Now().Add(-time.Hour).Truncate(time.Second),
+		ExpiresAt:             time.Now().Add(time.Hour).Truncate(time.Second),
+		LastSeenAt:            time.Now().Truncate(time.Second),
+		CreatedIP:             "203.0.113.7",
+		LastIP:                "203.0.113.8",
+		UserAgent:             "Mozilla/5.0",
+		Device:                "Work laptop",
+		AuthLevel:             AuthLevelMFA,
+		AuthenticatedAt:       time.Now().Add(-time.Minute).Truncate(time.Second),
+		Roles:                 []string{"admin"},
+		Scopes:                []string{"read", "write"},
+		RolesLoadedAt:         time.Now().Truncate(time.Second),
+		ImpersonatorID:        "admin",
+		ImpersonatorSessionID: admin.ID,
+		UserAgentHash:         sha256Hex("Mozilla/5.0"),
+		IPNetwork:             "203.0.113.0/24",
+		Values:                map[string]string{csrfSecretKey: "secret"},
+	}
+	_ = source.ImportSession(full)
+	_, _ = source.CreateSession("user2", -time.Minute)
+
+	var buf bytes.Buffer
+	exported, err := Export(&buf, source, ExportOptions{SkipExpired: true})
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if exported != 2 {
+		t.Errorf("Export() = %d, want 2 unexpired sessions", exported)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 3 {
+		t.Errorf("expected a header and 2 session lines, got %d lines", lines)
+	}
+
+	export := buf.Bytes()
+	forEachStore(t, func(t *testing.T, target SessionStore) {
+		imported, err := Import(bytes.NewReader(export), target, ImportOptions{})
+		if err != nil {
+			t.Fatalf("Import() error = %v", err)
+		}
+		if imported != 2 {
+			t.Errorf("Import() = %d, want 2", imported)
+		}
+
+		got, err := target.GetSession(full.ID)
+		if err != nil {
+			t.Fatalf("GetSession() error = %v", err)
+		}
+		for _, ts := range []*time.Time{&got.CreatedAt, &got.ExpiresAt, &got.LastSeenAt, &got.AuthenticatedAt, &got.RolesLoadedAt} {
+			*ts = ts.Local()
+		}
+		if !reflect.DeepEqual(got, full) {
+			t.Errorf("imported session differs:\n got %+v\nwant %+v", got, full)
+		}
+	})
+}
+
+func TestImport_RehashIDs(t *testing.T) {
+	source := NewInMemorySessionStore()
+	admin, _ := source.CreateSession("admin", time.Hour)
+	impersonation := &SessionData{
+		ID:                    generateSessionID(),
+		UserID:                "user1",
+		CreatedAt:             time.Now(),
+		ExpiresAt:             time.Now().Add(time.Hour),
+		ImpersonatorID:        "admin",
+		ImpersonatorSessionID: admin.ID,
+	}
+	_ = source.ImportSession(impersonation)
+
+	var buf bytes.Buffer
+	if _, err := Export(&buf, source, ExportOptions{}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	export := buf.Bytes()
+	forEachStore(t, func(t *testing.T, target SessionStore) {
+		if n, err := Import(bytes.NewReader(export), target, ImportOptions{RehashIDs: true}); err != nil || n != 2 {
+			t.Fatalf("Import() = %d, %v; want 2", n, err)
+		}
+
+		for _, old := range []string{admin.ID, impersonation.ID} {
+			if _, err := target.GetSession(old); !errors.Is(err, ErrSessionNotFound) {
+				t.Errorf("expected the exported ID to stop working, got %v", err)
+			}
+		}
+
+		sessions, _ := listSessions(target, SessionQuery{})
+		byUser := map[string]*SessionData{}
+		for _, session := range sessions {
+			if !isSessionIDFormat(session.ID) {
+				t.Errorf("rehashed ID %q is not a session ID", session.ID)
+			}
+			byUser[session.UserID] = session
+		}
+		if byUser["admin"] == nil || byUser["user1"] == nil {
+			t.Fatalf("expected both sessions to be imported, got %+v", sessions)
+		}
+		if byUser["user1"].ImpersonatorSessionID != byUser["admin"].ID {
+			t.Error("expected the impersonation to reference the rehashed admin session")
+		}
+	})
+}
+
+func TestImport_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"empty", ""},
+		{"other format", `{"format":"other","version":1}`},
+		{"newer version", `{"format":"sessions","version":2}`},
+		{"missing user", `{"format":"sessions","version":1}` + "\n" + `{"id":"abc"}`},
+		{"malformed line", `{"format":"sessions","version":1}` + "\n" + `{"id":`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Import(strings.NewReader(tt.input), NewInMemorySessionStore(), ImportOptions{}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	input := `{"format":"sessions","version":1}` + "\n" + `{"id":"abc","user_id":"user1"}`
+	if _, err := Import(strings.NewReader(input), &MockSessionStore{}, ImportOptions{}); err == nil {
+		t.Error("expected an error for a store without import support")
+	}
+
+	expired := `{"format":"sessions","version":1}` + "\n" + `{"id":"abc","user_id":"user1","expires_at":"2000-01-01T00:00:00Z"}`
+	store := NewInMemorySessionStore()
+	if n, err := Import(strings.NewReader(expired), store, ImportOptions{SkipExpired: true}); err != nil || n != 0 {
+		t.Errorf("Import() = %d, %v; want expired session skipped", n, err)
+	}
+	if _, err := store.GetSession("abc"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected skipped session to be absent, got %v", err)
+	}
+}
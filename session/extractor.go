@@ -0,0 +1,92 @@
+package session
+
+import (
+	"net/http"
+	"strings"
+)
+
+const bearerPrefix = "Bearer "
+
+// TokenExtractor retrieves a session token from an incoming request.
+type TokenExtractor interface {
+	ExtractToken(r *http.Request) (string, bool)
+}
+
+// TokenExtractorFunc adapts an ordinary function to the TokenExtractor interface.
+type TokenExtractorFunc func(r *http.Request) (string, bool)
+
+// ExtractToken calls f(r).
+func (f TokenExtractorFunc) ExtractToken(r *http.Request) (string, bool) {
+	return f(r)
+}
+
+// CookieExtractor reads the session token from a cookie.
+// An empty Name falls back to CookieName.
+type CookieExtractor struct {
+	Name string
+}
+
+// ExtractToken returns the value of the configured cookie.
+func (e CookieExtractor) ExtractToken(r *http.Request) (string, bool) {
+	name := e.Name
+	if name == "" {
+		name = CookieName
+	}
+
+	cookie, err := r.Cookie(name)
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+	return cookie.Value, true
+}
+
+// BearerExtractor reads the session token from an "Authorization: Bearer" header.
+type BearerExtractor struct{}
+
+// ExtractToken returns the token following the Bearer scheme.
+func (BearerExtractor) ExtractToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if len(header) < len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		return "", false
+	}
+
+	token := strings.TrimSpace(header[len(bearerPrefix):])
+	return token, token != ""
+}
+
+// HeaderExtractor reads the session token from a custom request header.
+type HeaderExtractor struct {
+	Name string
+}
+
+// ExtractToken returns the value of the configured header.
+func (e HeaderExtractor) ExtractToken(r *http.Request) (string, bool) {
+	token := strings.TrimSpace(r.Header.Get(e.Name))
+	return token, token != ""
+}
+
+// QueryExtractor reads the session token from a URL query parameter.
+type QueryExtractor struct {
+	Param string
+}
+
+// ExtractToken returns the value of the configured query parameter.
+func (e QueryExtractor) ExtractToken(r *http.Request) (string, bool) {
+	token := r.URL.Query().Get(e.Param)
+	return token, token != ""
+}
+
+// extractToken runs the configured extractors in priority order and returns
+// the first token found. Without extractors the session cookie is used.
+func (s *Session) extractToken(r *http.Request) (string, bool) {
+	if len(s.Extractors) == 0 {
+		return CookieExtractor{}.ExtractToken(r)
+	}
+
+	for _, extractor := range s.Extractors {
+		if token, ok := extractor.ExtractToken(r); ok {
+			return token, true
+		}
+	}
+	return "", false
+}
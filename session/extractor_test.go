@@ -0,0 +1,164 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTokenExtractors(t *testing.T) {
+	tests := []struct {
+		name      string
+		extractor TokenExtractor
+		setup     func(r *http.Request)
+		wantToken string
+		wantOK    bool
+	}{
+		{
+			name:      "cookie default name",
+			extractor: CookieExtractor{},
+			setup: func(r *http.Request) {
+				r.AddCookie(&http.Cookie{Name: CookieName, Value: "cookie-token"})
+			},
+			wantToken: "cookie-token",
+			wantOK:    true,
+		},
+		{
+			name:      "cookie custom name",
+			extractor: CookieExtractor{Name: "sid"},
+			setup: func(r *http.Request) {
+				r.AddCookie(&http.Cookie{Name: "sid", Value: "custom-token"})
+			},
+			wantToken: "custom-token",
+			wantOK:    true,
+		},
+		{
+			name:      "cookie missing",
+			extractor: CookieExtractor{},
+			setup:     func(r *http.Request) {},
+			wantOK:    false,
+		},
+		{
+			name:      "bearer token",
+			extractor: BearerExtractor{},
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer bearer-token")
+			},
+			wantToken: "bearer-token",
+			wantOK:    true,
+		},
+		{
+			name:      "bearer scheme is case insensitive",
+			extractor: BearerExtractor{},
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "bearer bearer-token")
+			},
+			wantToken: "bearer-token",
+			wantOK:    true,
+		},
+		{
+			name:      "bearer wrong scheme",
+			extractor: BearerExtractor{},
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+			},
+			wantOK: false,
+		},
+		{
+			name:      "bearer empty token",
+			extractor: BearerExtractor{},
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer ")
+			},
+			wantOK: false,
+		},
+		{
+			name:      "custom header",
+			extractor: HeaderExtractor{Name: "X-Session-Token"},
+			setup: func(r *http.Request) {
+				r.Header.Set("X-Session-Token", "header-token")
+			},
+			wantToken: "header-token",
+			wantOK:    true,
+		},
+		{
+			name:      "query parameter",
+			extractor: QueryExtractor{Param: "session"},
+			setup: func(r *http.Request) {
+				r.URL.RawQuery = "session=query-token"
+			},
+			wantToken: "query-token",
+			wantOK:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			tt.setup(req)
+
+			token, ok := tt.extractor.ExtractToken(req)
+			if ok != tt.wantOK {
+				t.Fatalf("ExtractToken() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if token != tt.wantToken {
+				t.Errorf("ExtractToken() token = %q, want %q", token, tt.wantToken)
+			}
+		})
+	}
+}
+
+func TestSession_ExtractorChain(t *testing.T) {
+	s := &Session{
+		Extractors: []TokenExtractor{
+			BearerExtractor{},
+			HeaderExtractor{Name: "X-Session-Token"},
+			CookieExtractor{},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		setup     func(r *http.Request)
+		wantToken string
+		wantOK    bool
+	}{
+		{
+			name: "first extractor wins",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer bearer-token")
+				r.AddCookie(&http.Cookie{Name: CookieName, Value: "cookie-token"})
+			},
+			wantToken: "bearer-token",
+			wantOK:    true,
+		},
+		{
+			name: "falls through to later extractor",
+			setup: func(r *http.Request) {
+				r.AddCookie(&http.Cookie{Name: CookieName, Value: "cookie-token"})
+			},
+			wantToken: "cookie-token",
+			wantOK:    true,
+		},
+		{
+			name:   "no token",
+			setup:  func(r *http.Request) {},
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			tt.setup(req)
+
+			token, ok := s.extractToken(req)
+			if ok != tt.wantOK {
+				t.Fatalf("extractToken() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if token != tt.wantToken {
+				t.Errorf("extractToken() token = %q, want %q", token, tt.wantToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+const flashesKey = "_flashes"
+
+// FlashType categorizes a flash message.
+type FlashType string
+
+const (
+	FlashInfo    FlashType = "info"
+	FlashSuccess FlashType = "success"
+	FlashWarning FlashType = "warning"
+	FlashError   FlashType = "error"
+)
+
+// Flash is a one-time message that survives until it is read, typically
+// across a redirect.
+type Flash struct {
+	Type    FlashType `json:"type"`
+	Message string    `json:"message"`
+}
+
+// errNoSession is returned by helpers that need a session in the request context.
+var errNoSession = errors.New("no session in context")
+
+// AddFlash queues a flash message on the session of the request.
+func (s *Session) AddFlash(r *http.Request, flash Flash) error {
+	sessionData, ok := GetSessionFromContext(r.Context())
+	if !ok {
+		return errNoSession
+	}
+
+	flashes, err := decodeFlashes(sessionData)
+	if err != nil {
+		return err
+	}
+
+	return s.saveFlashes(r, sessionData, append(flashes, flash))
+}
+
+// Flashes returns the queued flash messages of the request's session and
+// removes them, so each message is only shown once.
+func (s *Session) Flashes(r *http.Request) ([]Flash, error) {
+	sessionData, ok := GetSessionFromContext(r.Context())
+	if !ok {
+		return nil, errNoSession
+	}
+
+	flashes, err := decodeFlashes(sessionData)
+	if err != nil || len(flashes) == 0 {
+		return nil, err
+	}
+
+	if err := s.saveFlashes(r, sessionData, nil); err != nil {
+		return nil, err
+	}
+	return flashes, nil
+}
+
+// saveFlashes writes the flash queue to the session and persists it.
+func (s *Session) saveFlashes(r *http.Request, sessionData *SessionData, flashes []Flash) error {
+	if len(flashes) == 0 {
+		delete(sessionData.Values, flashesKey)
+	} else {
+		data, err := json.Marshal(flashes)
+		if err != nil {
+			return err
+		}
+
+		if sessionData.Values == nil {
+			sessionData.Values = make(map[string]string)
+		}
+		sessionData.Values[flashesKey] = string(data)
+	}
+
+	return updateSession(s.store(r), sessionData)
+}
+
+// decodeFlashes reads the flash queue stored in a session.
+func decodeFlashes(sessionData *SessionData) ([]Flash, error) {
+	data, ok := sessionData.Values[flashesKey]
+	if !ok {
+		return nil, nil
+	}
+
+	var flashes []Flash
+	if err := json.Unmarshal([]byte(data), &flashes); err != nil {
+		return nil, err
+	}
+	return flashes, nil
+}
@@ -0,0 +1,80 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSession_Flashes(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store SessionStore) {
+		s := &Session{Store: store}
+		created, _ := store.CreateSession("user1", time.Hour)
+
+		// request simulates a new request that loads the session from the store.
+		request := func() *http.Request {
+			sessionData, err := store.GetSession(created.ID)
+			if err != nil {
+				t.Fatalf("GetSession() error = %v", err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			return req.WithContext(WithSession(req.Context(), sessionData))
+		}
+
+		req := request()
+		if err := s.AddFlash(req, Flash{Type: FlashSuccess, Message: "Saved!"}); err != nil {
+			t.Fatalf("AddFlash() error = %v", err)
+		}
+		if err := s.AddFlash(req, Flash{Type: FlashError, Message: "Name is required"}); err != nil {
+			t.Fatalf("AddFlash() error = %v", err)
+		}
+
+		flashes, err := s.Flashes(request())
+		if err != nil {
+			t.Fatalf("Flashes() error = %v", err)
+		}
+		if len(flashes) != 2 {
+			t.Fatalf("expected 2 flashes, got %d", len(flashes))
+		}
+		if flashes[0] != (Flash{Type: FlashSuccess, Message: "Saved!"}) {
+			t.Errorf("unexpected first flash %v", flashes[0])
+		}
+		if flashes[1] != (Flash{Type: FlashError, Message: "Name is required"}) {
+			t.Errorf("unexpected second flash %v", flashes[1])
+		}
+
+		flashes, err = s.Flashes(request())
+		if err != nil {
+			t.Fatalf("Flashes() error = %v", err)
+		}
+		if len(flashes) != 0 {
+			t.Errorf("expected flashes to be removed once read, got %v", flashes)
+		}
+	})
+}
+
+func TestSession_FlashesWithoutSession(t *testing.T) {
+	s := &Session{Store: NewInMemorySessionStore()}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	if err := s.AddFlash(req, Flash{Type: FlashInfo, Message: "hello"}); err == nil {
+		t.Error("expected AddFlash() to fail without a session")
+	}
+	if _, err := s.Flashes(req); err == nil {
+		t.Error("expected Flashes() to fail without a session")
+	}
+}
+
+func TestSession_FlashesStoreWithoutUpdates(t *testing.T) {
+	store := NewInMemorySessionStore()
+	sessionData, _ := store.CreateSession("user1", time.Hour)
+	s := &Session{Store: basicStore{store}}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(WithSession(req.Context(), sessionData))
+	if err := s.AddFlash(req, Flash{Type: FlashInfo, Message: "hello"}); !errors.Is(err, ErrUpdateNotSupported) {
+		t.Errorf("expected ErrUpdateNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package session
+
+import "testing"
+
+// forEachStore runs test as a subtest against every built-in store.
+func forEachStore(t *testing.T, test func(t *testing.T, store SessionStore)) {
+	t.Helper()
+
+	stores := []struct {
+		name  string
+		store func(t *testing.T) SessionStore
+	}{
+		{"in_memory", func(t *testing.T) SessionStore { return NewInMemorySessionStore() }},
+		{"sql", func(t *testing.T) SessionStore { return setupTestDB(t) }},
+	}
+
+	for _, st := range stores {
+		t.Run(st.name, func(t *testing.T) {
+			test(t, st.store(t))
+		})
+	}
+}
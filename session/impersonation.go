@@ -0,0 +1,130 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// DefaultImpersonationDuration is the lifetime of an impersonation session
+// when Impersonate is called without a duration.
+const DefaultImpersonationDuration = 15 * time.Minute
+
+var (
+	// ErrAlreadyImpersonating is returned when an impersonation session tries to impersonate again.
+	ErrAlreadyImpersonating = errors.New("already impersonating")
+	// ErrNotImpersonating is returned by EndImpersonation for regular sessions.
+	ErrNotImpersonating = errors.New("not impersonating")
+	// ErrImpersonationForbidden is returned when Session.Impersonators does not
+	// authorize the request to impersonate.
+	ErrImpersonationForbidden = errors.New("impersonation forbidden")
+)
+
+// Impersonate lets the admin of the request's session act as userID. The
+// request must be authorized by Session.Impersonators, such as AdminRoles
+// for the admin role, or ErrImpersonationForbidden is returned. A new,
+// shorter session is created for userID that records the admin as its
+// impersonator, and the session cookie is switched to it. The admin session
+// stays valid so EndImpersonation can return to it. The impersonation never
+// outlives the admin session. It does not count against the session limit
+// of userID, so the user's own sessions are never evicted for it; the store
+// must implement SessionImporter.
+func (s *Session) Impersonate(w http.ResponseWriter, r *http.Request, userID string, duration time.Duration) (*SessionData, error) {
+	admin, ok := GetSessionFromContext(r.Context())
+	if !ok {
+		return nil, errNoSession
+	}
+	if admin.IsImpersonation() {
+		return nil, ErrAlreadyImpersonating
+	}
+	if s.Impersonators == nil || !s.Impersonators.AuthorizeAdmin(r) {
+		return nil, ErrImpersonationForbidden
+	}
+
+	if duration <= 0 {
+		duration = DefaultImpersonationDuration
+	}
+
+	if userID == "" {
+		return nil, errors.New("user ID is required")
+	}
+
+	now := time.Now()
+	sessionData := &SessionData{
+		ID:                    generateSessionID(),
+		UserID:                userID,
+		Tenant:                admin.Tenant,
+		CreatedAt:             now,
+		ExpiresAt:             now.Add(duration),
+		LastSeenAt:            now,
+		ImpersonatorID:        admin.UserID,
+		ImpersonatorSessionID: admin.ID,
+	}
+	if sessionData.ExpiresAt.After(admin.ExpiresAt) {
+		sessionData.ExpiresAt = admin.ExpiresAt
+	}
+	s.recordClient(r, sessionData)
+
+	// Importing skips the session limit that CreateSession enforces.
+	if err := importSession(s.store(r), sessionData); err != nil {
+		return nil, err
+	}
+
+	http.SetCookie(w, newSessionCookie(sessionData.ID, sessionData.ExpiresAt))
+	s.audit(r, AuditEvent{
+		Type:    AuditSessionCreated,
+		Session: RedactSessionID(sessionData.ID),
+		UserID:  userID,
+		Actor:   admin.UserID,
+		Tenant:  sessionData.Tenant,
+		Reason:  auditReasonImpersonationStart,
+	})
+	return sessionData, nil
+}
+
+// EndImpersonation revokes the impersonation session of the request and
+// switches the session cookie back to the admin session, which is returned.
+func (s *Session) EndImpersonation(w http.ResponseWriter, r *http.Request) (*SessionData, error) {
+	sessionData, ok := GetSessionFromContext(r.Context())
+	if !ok {
+		return nil, errNoSession
+	}
+	if !sessionData.IsImpersonation() {
+		return nil, ErrNotImpersonating
+	}
+
+	if err := s.storeFor(r, auditReasonImpersonationEnd).DeleteSession(sessionData.ID); err != nil {
+		return nil, err
+	}
+
+	admin, err := s.store(r).GetSession(sessionData.ImpersonatorSessionID)
+	if err != nil {
+		ClearSessionCookie(w)
+		return nil, err
+	}
+
+	http.SetCookie(w, newSessionCookie(admin.ID, admin.ExpiresAt))
+	return admin, nil
+}
+
+// IsImpersonation reports whether the session was created by Impersonate.
+func (d *SessionData) IsImpersonation() bool {
+	return d.ImpersonatorID != ""
+}
+
+// IsImpersonating reports whether the session in a context is an impersonation.
+func IsImpersonating(ctx context.Context) bool {
+	session, ok := GetSessionFromContext(ctx)
+	return ok && session.IsImpersonation()
+}
+
+// ImpersonatorFromContext returns the user ID of the admin impersonating the
+// user of the session in a context.
+func ImpersonatorFromContext(ctx context.Context) (string, bool) {
+	session, ok := GetSessionFromContext(ctx)
+	if !ok || !session.IsImpersonation() {
+		return "", false
+	}
+	return session.ImpersonatorID, true
+}
@@ -0,0 +1,167 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSession_Impersonation(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store SessionStore) {
+		s := &Session{Store: store, Impersonators: AdminRoles("admin")}
+
+		admin, err := s.Start(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/login", nil),
+			"admin1", StartOptions{Duration: time.Hour, Roles: []string{"admin"}})
+		if err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/impersonate", nil)
+		req = req.WithContext(WithSession(req.Context(), admin))
+		rr := httptest.NewRecorder()
+
+		impersonation, err := s.Impersonate(rr, req, "customer1", 0)
+		if err != nil {
+			t.Fatalf("Impersonate() error = %v", err)
+		}
+		if impersonation.UserID != "customer1" {
+			t.Errorf("expected impersonated user customer1, got %q", impersonation.UserID)
+		}
+		if got := impersonation.ExpiresAt.Sub(impersonation.CreatedAt).Round(time.Second); got != DefaultImpersonationDuration {
+			t.Errorf("expected impersonation lifetime %v, got %v", DefaultImpersonationDuration, got)
+		}
+		if cookies := rr.Result().Cookies(); len(cookies) != 1 || cookies[0].Value != impersonation.ID {
+			t.Errorf("expected session cookie to switch to the impersonation, got %v", cookies)
+		}
+
+		// Downstream handlers see the impersonation through the context.
+		stored, err := store.GetSession(impersonation.ID)
+		if err != nil {
+			t.Fatalf("GetSession() error = %v", err)
+		}
+		ctx := WithSession(req.Context(), stored)
+		if !IsImpersonating(ctx) {
+			t.Error("expected IsImpersonating() to be true")
+		}
+		if actor, ok := ImpersonatorFromContext(ctx); !ok || actor != "admin1" {
+			t.Errorf("ImpersonatorFromContext() = %q, %v, want admin1, true", actor, ok)
+		}
+
+		nested := httptest.NewRequest(http.MethodPost, "/impersonate", nil).WithContext(ctx)
+		if _, err := s.Impersonate(httptest.NewRecorder(), nested, "customer2", 0); !errors.Is(err, ErrAlreadyImpersonating) {
+			t.Errorf("Impersonate() nested error = %v, want %v", err, ErrAlreadyImpersonating)
+		}
+
+		end := httptest.NewRequest(http.MethodPost, "/end-impersonation", nil).WithContext(ctx)
+		rr = httptest.NewRecorder()
+		restored, err := s.EndImpersonation(rr, end)
+		if err != nil {
+			t.Fatalf("EndImpersonation() error = %v", err)
+		}
+		if restored.ID != admin.ID {
+			t.Errorf("expected admin session %q to be restored, got %q", admin.ID, restored.ID)
+		}
+		if cookies := rr.Result().Cookies(); len(cookies) != 1 || cookies[0].Value != admin.ID {
+			t.Errorf("expected session cookie to switch back to the admin session, got %v", cookies)
+		}
+		if _, err := store.GetSession(impersonation.ID); err == nil {
+			t.Error("expected impersonation session to be revoked")
+		}
+
+		adminReq := httptest.NewRequest(http.MethodPost, "/end-impersonation", nil)
+		adminReq = adminReq.WithContext(WithSession(adminReq.Context(), admin))
+		if _, err := s.EndImpersonation(httptest.NewRecorder(), adminReq); !errors.Is(err, ErrNotImpersonating) {
+			t.Errorf("EndImpersonation() on admin session error = %v, want %v", err, ErrNotImpersonating)
+		}
+	})
+}
+
+func TestSession_ImpersonationForbidden(t *testing.T) {
+	store := NewInMemorySessionStore()
+	user, _ := store.CreateSession("user1", time.Hour)
+	user.Roles = []string{"support"}
+	req := httptest.NewRequest(http.MethodPost, "/impersonate", nil)
+	req = req.WithContext(WithSession(req.Context(), user))
+
+	tests := []struct {
+		name          string
+		impersonators AdminAuthorizer
+	}{
+		{"no authorizer", nil},
+		{"not an admin", AdminRoles("admin")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Session{Store: store, Impersonators: tt.impersonators}
+			rr := httptest.NewRecorder()
+			if _, err := s.Impersonate(rr, req, "admin1", 0); !errors.Is(err, ErrImpersonationForbidden) {
+				t.Errorf("Impersonate() error = %v, want %v", err, ErrImpersonationForbidden)
+			}
+			if cookies := rr.Result().Cookies(); len(cookies) != 0 {
+				t.Errorf("expected no session cookie, got %v", cookies)
+			}
+		})
+	}
+
+	if sessions, _ := store.ListSessions(SessionQuery{UserID: "admin1"}); len(sessions) != 0 {
+		t.Errorf("expected no impersonation session, got %d", len(sessions))
+	}
+}
+
+func TestSession_ImpersonationCappedByAdminSession(t *testing.T) {
+	store := NewInMemorySessionStore()
+	s := &Session{Store: store, Impersonators: AdminRoles("admin")}
+
+	admin, _ := store.CreateSession("admin1", 5*time.Minute)
+	admin.Roles = []string{"admin"}
+	req := httptest.NewRequest(http.MethodPost, "/impersonate", nil)
+	req = req.WithContext(WithSession(req.Context(), admin))
+
+	impersonation, err := s.Impersonate(httptest.NewRecorder(), req, "customer1", time.Hour)
+	if err != nil {
+		t.Fatalf("Impersonate() error = %v", err)
+	}
+	if impersonation.ExpiresAt.After(admin.ExpiresAt) {
+		t.Errorf("expected impersonation to expire by %v, got %v", admin.ExpiresAt, impersonation.ExpiresAt)
+	}
+}
+
+func TestSession_ImpersonationIgnoresSessionLimit(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store SessionStore) {
+		store.(limitedStore).setLimit(SessionLimit{MaxSessions: 1, Strategy: EvictOldest})
+		sink := &recordingSink{}
+		s := &Session{Store: store, Audit: sink, Impersonators: AdminRoles("admin")}
+
+		customer, _ := store.CreateSession("customer1", time.Hour)
+		admin, _ := store.CreateSession("admin1", time.Hour)
+		admin.Roles = []string{"admin"}
+		req := httptest.NewRequest(http.MethodPost, "/impersonate", nil)
+		req = req.WithContext(WithSession(req.Context(), admin))
+
+		impersonation, err := s.Impersonate(httptest.NewRecorder(), req, "customer1", 0)
+		if err != nil {
+			t.Fatalf("Impersonate() error = %v", err)
+		}
+		if _, err := store.GetSession(customer.ID); err != nil {
+			t.Errorf("expected the customer's own session to survive, got %v", err)
+		}
+
+		events := sink.take()
+		if len(events) != 1 {
+			t.Fatalf("expected one audit event, got %+v", events)
+		}
+		want := AuditEvent{
+			Type:    AuditSessionCreated,
+			Session: RedactSessionID(impersonation.ID),
+			UserID:  "customer1",
+			Actor:   "admin1",
+			Reason:  auditReasonImpersonationStart,
+		}
+		if got := events[0]; got.Type != want.Type || got.Session != want.Session || got.UserID != want.UserID ||
+			got.Actor != want.Actor || got.Reason != want.Reason {
+			t.Errorf("audit event = %+v, want %+v", got, want)
+		}
+	})
+}
@@ -2,64 +2,389 @@ package session
 
 import (
 	"errors"
+	"log/slog"
+	"sort"
 	"sync"
 	"time"
 )
 
 type InMemorySessionStore struct {
-	sessions map[string]*SessionData
-	mutex    sync.RWMutex
+	// Limit caps the number of active sessions per user.
+	Limit SessionLimit
+
+	// Logger receives expiries and cleanup runs, if set.
+	Logger *slog.Logger
+
+	sessions       map[string]*SessionData
+	rememberTokens map[string]*RememberToken
+	mutex          sync.RWMutex
+
+	// persist is set by OpenInMemorySessionStore.
+	persist *persister
 }
 
 func NewInMemorySessionStore() *InMemorySessionStore {
 	return &InMemorySessionStore{
-		sessions: make(map[string]*SessionData),
+		sessions:       make(map[string]*SessionData),
+		rememberTokens: make(map[string]*RememberToken),
 	}
 }
 
 func (s *InMemorySessionStore) CreateSession(userID string, duration time.Duration) (*SessionData, error) {
+	session, _, err := s.createSession(allTenants, userID, duration)
+	return session, err
+}
+
+func (s *InMemorySessionStore) CreateSessionEvicting(userID string, duration time.Duration) (*SessionData, []*SessionData, error) {
+	return s.createSession(allTenants, userID, duration)
+}
+
+func (s *InMemorySessionStore) createSession(scope tenantScope, userID string, duration time.Duration) (*SessionData, []*SessionData, error) {
+	now := time.Now()
 	session := &SessionData{
-		ID:        generateSessionID(),
-		UserID:    userID,
-		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(duration),
+		ID:         generateSessionID(),
+		UserID:     userID,
+		Tenant:     scope.tenant,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(duration),
+		LastSeenAt: now,
 	}
 
 	s.mutex.Lock()
-	s.sessions[session.ID] = session
-	s.mutex.Unlock()
+	defer s.mutex.Unlock()
+
+	evict, err := s.Limit.evictions(s.activeSessions(session.Tenant, userID, now))
+	if err != nil {
+		return nil, nil, err
+	}
+	evicted := make([]*SessionData, 0, len(evict))
+	for _, old := range evict {
+		evicted = append(evicted, old.clone())
+		delete(s.sessions, old.ID)
+		s.record(changeEntry{Op: opDeleteSession, ID: old.ID})
+	}
 
-	return session, nil
+	s.sessions[session.ID] = session.clone()
+	s.record(putSessionEntry(session))
+	return session, evicted, nil
+}
+
+// activeSessions returns the unexpired sessions of a user within a tenant.
+// The caller must hold the lock.
+func (s *InMemorySessionStore) activeSessions(tenant, userID string, now time.Time) []*SessionData {
+	var active []*SessionData
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.Tenant == tenant && !session.ExpiresAt.Before(now) {
+			active = append(active, session)
+		}
+	}
+	return active
 }
 
 func (s *InMemorySessionStore) GetSession(sessionID string) (*SessionData, error) {
+	return s.getSession(allTenants, sessionID)
+}
+
+func (s *InMemorySessionStore) getSession(scope tenantScope, sessionID string) (*SessionData, error) {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
 	session, exists := s.sessions[sessionID]
-	if !exists || session.ExpiresAt.Before(time.Now()) {
-		return nil, errors.New("session not found or expired")
+	if !exists || !scope.allows(session) {
+		return nil, ErrSessionNotFound
+	}
+	if session.ExpiresAt.Before(time.Now()) {
+		loggerOrDiscard(s.Logger).Debug("session expired", sessionAttr(sessionID))
+		return nil, ErrSessionExpired
+	}
+
+	return session.clone(), nil
+}
+
+func (s *InMemorySessionStore) UpdateSession(session *SessionData) error {
+	return s.updateSession(allTenants, session)
+}
+
+func (s *InMemorySessionStore) updateSession(scope tenantScope, session *SessionData) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	existing, exists := s.sessions[session.ID]
+	if !exists || !scope.allows(existing) {
+		return ErrSessionNotFound
+	}
+
+	// The tenant of a session never changes after creation.
+	updated := session.clone()
+	updated.Tenant = existing.Tenant
+	s.sessions[session.ID] = updated
+	s.record(putSessionEntry(updated))
+	return nil
+}
+
+func (s *InMemorySessionStore) TouchSession(sessionID string, activity SessionActivity) error {
+	return s.touchSession(allTenants, sessionID, activity)
+}
+
+func (s *InMemorySessionStore) touchSession(scope tenantScope, sessionID string, activity SessionActivity) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, exists := s.sessions[sessionID]
+	if !exists || !scope.allows(session) {
+		return ErrSessionNotFound
 	}
 
-	return session, nil
+	session.LastSeenAt = activity.SeenAt
+	if activity.IP != "" {
+		session.LastIP = activity.IP
+	}
+	s.record(putSessionEntry(session))
+	return nil
 }
 
 func (s *InMemorySessionStore) DeleteSession(sessionID string) error {
+	return s.deleteSession(allTenants, sessionID)
+}
+
+func (s *InMemorySessionStore) deleteSession(scope tenantScope, sessionID string) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
-	delete(s.sessions, sessionID)
+
+	if session, exists := s.sessions[sessionID]; exists && scope.allows(session) {
+		delete(s.sessions, sessionID)
+		s.record(changeEntry{Op: opDeleteSession, ID: sessionID})
+	}
 	return nil
 }
 
 func (s *InMemorySessionStore) CleanupExpiredSessions() error {
+	_, err := s.purgeExpiredSessions(allTenants)
+	return err
+}
+
+// PurgeExpiredSessions removes expired sessions and remember-me tokens and
+// returns the removed sessions.
+func (s *InMemorySessionStore) PurgeExpiredSessions() ([]*SessionData, error) {
+	return s.purgeExpiredSessions(allTenants)
+}
+
+func (s *InMemorySessionStore) purgeExpiredSessions(scope tenantScope) ([]*SessionData, error) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
+	var purged []*SessionData
 	for id, session := range s.sessions {
-		if session.ExpiresAt.Before(time.Now()) {
+		if scope.allows(session) && session.ExpiresAt.Before(time.Now()) {
+			purged = append(purged, session)
 			delete(s.sessions, id)
+			s.record(changeEntry{Op: opDeleteSession, ID: id})
+		}
+	}
+
+	for selector, token := range s.rememberTokens {
+		if (!scope.scoped || token.Tenant == scope.tenant) && token.ExpiresAt.Before(time.Now()) {
+			delete(s.rememberTokens, selector)
+			s.record(changeEntry{Op: opDeleteToken, ID: selector})
+		}
+	}
+
+	loggerOrDiscard(s.Logger).Debug("expired sessions cleaned up", slog.Int("deleted", len(purged)))
+	return purged, nil
+}
+
+// CountActiveSessions returns the number of unexpired sessions.
+func (s *InMemorySessionStore) CountActiveSessions() (int, error) {
+	return s.countActiveSessions(allTenants)
+}
+
+func (s *InMemorySessionStore) countActiveSessions(scope tenantScope) (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	now := time.Now()
+	count := 0
+	for _, session := range s.sessions {
+		if scope.allows(session) && !session.ExpiresAt.Before(now) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ListSessions returns the sessions matching query, ordered by ID.
+func (s *InMemorySessionStore) ListSessions(query SessionQuery) ([]*SessionData, error) {
+	return s.listSessions(allTenants, query)
+}
+
+func (s *InMemorySessionStore) listSessions(scope tenantScope, query SessionQuery) ([]*SessionData, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	now := time.Now()
+	var sessions []*SessionData
+	for _, session := range s.sessions {
+		if !scope.allows(session) || session.ID <= query.After {
+			continue
+		}
+		if query.UserID != "" && session.UserID != query.UserID {
+			continue
+		}
+		if !query.IncludeExpired && session.ExpiresAt.Before(now) {
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	if query.Limit > 0 && len(sessions) > query.Limit {
+		sessions = sessions[:query.Limit]
+	}
+	for i, session := range sessions {
+		sessions[i] = session.clone()
+	}
+	return sessions, nil
+}
+
+// ImportSession stores session with its ID, replacing any session with the same ID.
+func (s *InMemorySessionStore) ImportSession(session *SessionData) error {
+	return s.importSession(allTenants, session)
+}
+
+func (s *InMemorySessionStore) importSession(scope tenantScope, session *SessionData) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if existing, exists := s.sessions[session.ID]; exists && !scope.allows(existing) {
+		return errTenantMismatch
+	}
+
+	imported := session.clone()
+	if scope.scoped {
+		imported.Tenant = scope.tenant
+	}
+	s.sessions[session.ID] = imported
+	s.record(putSessionEntry(imported))
+	return nil
+}
+
+// ForTenant returns a view of the store restricted to the sessions of one
+// tenant. Sessions created through it belong to the tenant, and sessions of
+// other tenants cannot be read, changed or deleted through it.
+func (s *InMemorySessionStore) ForTenant(tenant string) SessionStore {
+	return &inMemoryTenantStore{store: s, scope: tenantScope{tenant: tenant, scoped: true}}
+}
+
+// inMemoryTenantStore is the tenant-scoped view returned by InMemorySessionStore.ForTenant.
+type inMemoryTenantStore struct {
+	store *InMemorySessionStore
+	scope tenantScope
+}
+
+func (t *inMemoryTenantStore) CreateSession(userID string, duration time.Duration) (*SessionData, error) {
+	session, _, err := t.store.createSession(t.scope, userID, duration)
+	return session, err
+}
+
+func (t *inMemoryTenantStore) CreateSessionEvicting(userID string, duration time.Duration) (*SessionData, []*SessionData, error) {
+	return t.store.createSession(t.scope, userID, duration)
+}
+
+func (t *inMemoryTenantStore) GetSession(sessionID string) (*SessionData, error) {
+	return t.store.getSession(t.scope, sessionID)
+}
+
+func (t *inMemoryTenantStore) UpdateSession(session *SessionData) error {
+	return t.store.updateSession(t.scope, session)
+}
+
+func (t *inMemoryTenantStore) TouchSession(sessionID string, activity SessionActivity) error {
+	return t.store.touchSession(t.scope, sessionID, activity)
+}
+
+func (t *inMemoryTenantStore) DeleteSession(sessionID string) error {
+	return t.store.deleteSession(t.scope, sessionID)
+}
+
+func (t *inMemoryTenantStore) CleanupExpiredSessions() error {
+	_, err := t.store.purgeExpiredSessions(t.scope)
+	return err
+}
+
+func (t *inMemoryTenantStore) PurgeExpiredSessions() ([]*SessionData, error) {
+	return t.store.purgeExpiredSessions(t.scope)
+}
+
+func (t *inMemoryTenantStore) CountActiveSessions() (int, error) {
+	return t.store.countActiveSessions(t.scope)
+}
+
+func (t *inMemoryTenantStore) ListSessions(query SessionQuery) ([]*SessionData, error) {
+	return t.store.listSessions(t.scope, query)
+}
+
+// ImportSession imports session into the tenant, whatever its Tenant field
+// says. It fails if a session of another tenant has the same ID.
+func (t *inMemoryTenantStore) ImportSession(session *SessionData) error {
+	return t.store.importSession(t.scope, session)
+}
+
+func (s *InMemorySessionStore) SaveRememberToken(token *RememberToken) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stored := *token
+	s.rememberTokens[token.Selector] = &stored
+	s.record(putTokenEntry(&stored))
+	return nil
+}
+
+func (s *InMemorySessionStore) RotateRememberToken(token *RememberToken) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	current, exists := s.rememberTokens[token.Selector]
+	if !exists || current.ValidatorHash != token.PreviousValidatorHash {
+		return false, nil
+	}
+
+	stored := *token
+	s.rememberTokens[token.Selector] = &stored
+	s.record(putTokenEntry(&stored))
+	return true, nil
+}
+
+func (s *InMemorySessionStore) GetRememberToken(selector string) (*RememberToken, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	token, exists := s.rememberTokens[selector]
+	if !exists {
+		return nil, errors.New("remember-me token not found")
+	}
+
+	found := *token
+	return &found, nil
+}
+
+func (s *InMemorySessionStore) DeleteRememberToken(selector string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.rememberTokens, selector)
+	s.record(changeEntry{Op: opDeleteToken, ID: selector})
+	return nil
+}
+
+func (s *InMemorySessionStore) DeleteUserRememberTokens(tenant, userID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for selector, token := range s.rememberTokens {
+		if token.UserID == userID && token.Tenant == tenant {
+			delete(s.rememberTokens, selector)
 		}
 	}
+	s.record(changeEntry{Op: opDeleteUserTokens, UserID: userID, Tenant: tenant})
 
 	return nil
 }
@@ -192,3 +192,53 @@ func TestInMemorySessionStore_CleanupExpiredSessions(t *testing.T) {
 		})
 	}
 }
+
+func TestInMemorySessionStore_UpdateSession(t *testing.T) {
+	tests := []struct {
+		name      string
+		setup     func(store *InMemorySessionStore) *SessionData
+		wantError bool
+	}{
+		{
+			"update_existing_session",
+			func(store *InMemorySessionStore) *SessionData {
+				session, _ := store.CreateSession("user1", time.Minute)
+				return session
+			},
+			false,
+		},
+		{
+			"update_nonexistent_session",
+			func(store *InMemorySessionStore) *SessionData {
+				return &SessionData{ID: "invalidID"}
+			},
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewInMemorySessionStore()
+			session := tt.setup(store)
+			session.Values = map[string]string{"key": "value"}
+
+			err := store.UpdateSession(session)
+			if (err != nil) != tt.wantError {
+				t.Fatalf("UpdateSession() error = %v, wantError %v", err, tt.wantError)
+			}
+			if tt.wantError {
+				return
+			}
+
+			session.Values["key"] = "changed after update"
+
+			stored, err := store.GetSession(session.ID)
+			if err != nil {
+				t.Fatalf("GetSession() error = %v", err)
+			}
+			if stored.Values["key"] != "value" {
+				t.Errorf("Expected stored value = %v, got = %v", "value", stored.Values["key"])
+			}
+		})
+	}
+}
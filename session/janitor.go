@@ -0,0 +1,56 @@
+package session
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// DefaultJanitorInterval is the time between two cleanups when
+// Janitor.Interval is not set.
+const DefaultJanitorInterval = 5 * time.Minute
+
+// Janitor periodically removes expired sessions and remember-me tokens from
+// a store. When the store is wrapped with WithEvents, an expiry event is
+// published for every session it removes.
+type Janitor struct {
+	Store SessionStore
+
+	// Interval defaults to DefaultJanitorInterval.
+	Interval time.Duration
+
+	// Logger, if set, receives failed cleanups.
+	Logger *slog.Logger
+}
+
+// Run cleans up on every interval until ctx is done.
+func (j *Janitor) Run(ctx context.Context) {
+	interval := j.Interval
+	if interval <= 0 {
+		interval = DefaultJanitorInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := j.Sweep(); err != nil {
+				loggerOrDiscard(j.Logger).Error("session cleanup failed", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// Sweep runs a single cleanup and returns the number of sessions removed,
+// which is only known for stores implementing ExpiredSessionPurger.
+func (j *Janitor) Sweep() (int, error) {
+	if purger, ok := j.Store.(ExpiredSessionPurger); ok {
+		purged, err := purger.PurgeExpiredSessions()
+		return len(purged), err
+	}
+	return 0, j.Store.CleanupExpiredSessions()
+}
@@ -0,0 +1,53 @@
+package session
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrSessionLimitReached is returned by CreateSession when a user already
+// has the maximum number of active sessions and the RejectNew strategy is used.
+var ErrSessionLimitReached = errors.New("session limit reached")
+
+// LimitStrategy decides which session gives way when a user exceeds the
+// session limit.
+type LimitStrategy int
+
+const (
+	// EvictOldest revokes the sessions that were created first.
+	EvictOldest LimitStrategy = iota
+	// RejectNew refuses to create the new session.
+	RejectNew
+	// EvictLeastRecentlyUsed revokes the sessions that were seen last the longest time ago.
+	EvictLeastRecentlyUsed
+)
+
+// SessionLimit caps the number of active sessions per user.
+type SessionLimit struct {
+	// MaxSessions is the number of active sessions a user may hold. Zero
+	// means unlimited.
+	MaxSessions int
+	Strategy    LimitStrategy
+}
+
+// evictions returns the active sessions of a user that have to be revoked
+// to make room for one more session.
+func (l SessionLimit) evictions(active []*SessionData) ([]*SessionData, error) {
+	if l.MaxSessions <= 0 || len(active) < l.MaxSessions {
+		return nil, nil
+	}
+	if l.Strategy == RejectNew {
+		return nil, ErrSessionLimitReached
+	}
+
+	sorted := make([]*SessionData, len(active))
+	copy(sorted, active)
+	sort.Slice(sorted, func(i, j int) bool {
+		if l.Strategy == EvictLeastRecentlyUsed {
+			return sorted[i].lastSeen().Before(sorted[j].lastSeen())
+		}
+		return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+	})
+
+	return sorted[:len(sorted)-l.MaxSessions+1], nil
+}
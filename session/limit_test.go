@@ -0,0 +1,109 @@
+package session
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// limitedStore is implemented by the stores supporting session limits.
+type limitedStore interface {
+	SessionStore
+	setLimit(limit SessionLimit)
+}
+
+func (s *InMemorySessionStore) setLimit(limit SessionLimit) { s.Limit = limit }
+func (s *DBSessionStore) setLimit(limit SessionLimit)       { s.Limit = limit }
+
+func TestSessionLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategy   LimitStrategy
+		touchFirst bool
+		wantErr    error
+		wantFirst  bool
+		wantSecond bool
+	}{
+		{"evict oldest", EvictOldest, false, nil, false, true},
+		{"reject new", RejectNew, false, ErrSessionLimitReached, true, true},
+		{"evict least recently used", EvictLeastRecentlyUsed, true, nil, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachStore(t, func(t *testing.T, store SessionStore) {
+				store.(limitedStore).setLimit(SessionLimit{MaxSessions: 2, Strategy: tt.strategy})
+
+				first, _ := store.CreateSession("user1", time.Hour)
+				time.Sleep(time.Millisecond)
+				second, _ := store.CreateSession("user1", time.Hour)
+				other, _ := store.CreateSession("user2", time.Hour)
+
+				if tt.touchFirst {
+					_ = touchSession(store, first.ID, SessionActivity{SeenAt: time.Now().Add(time.Minute)})
+				}
+
+				third, evicted, err := createSessionEvicting(store, "user1", time.Hour)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CreateSession() error = %v, want %v", err, tt.wantErr)
+				}
+				if tt.wantErr == nil {
+					if _, err := store.GetSession(third.ID); err != nil {
+						t.Errorf("new session is not active: %v", err)
+					}
+				}
+
+				// The store reports the session it evicted.
+				var wantEvicted []string
+				for _, kept := range []struct {
+					session *SessionData
+					want    bool
+				}{{first, tt.wantFirst}, {second, tt.wantSecond}} {
+					if !kept.want {
+						wantEvicted = append(wantEvicted, kept.session.ID)
+					}
+				}
+				var gotEvicted []string
+				for _, session := range evicted {
+					gotEvicted = append(gotEvicted, session.ID)
+				}
+				if !slices.Equal(gotEvicted, wantEvicted) {
+					t.Errorf("evicted = %v, want %v", gotEvicted, wantEvicted)
+				}
+
+				if _, err := store.GetSession(first.ID); (err == nil) != tt.wantFirst {
+					t.Errorf("first session active = %v, want %v", err == nil, tt.wantFirst)
+				}
+				if _, err := store.GetSession(second.ID); (err == nil) != tt.wantSecond {
+					t.Errorf("second session active = %v, want %v", err == nil, tt.wantSecond)
+				}
+				if _, err := store.GetSession(other.ID); err != nil {
+					t.Errorf("session of another user was evicted: %v", err)
+				}
+			})
+		})
+	}
+}
+
+func TestInMemorySessionStore_SessionLimitConcurrent(t *testing.T) {
+	store := NewInMemorySessionStore()
+	store.Limit = SessionLimit{MaxSessions: 3, Strategy: RejectNew}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = store.CreateSession("user1", time.Hour)
+		}()
+	}
+	wg.Wait()
+
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	if len(store.sessions) != 3 {
+		t.Errorf("Expected session count = %v, got = %v", 3, len(store.sessions))
+	}
+}
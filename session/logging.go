@@ -0,0 +1,49 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// RedactSessionID returns a short reference to a session that can be logged
+// in place of its ID. The reference is stable, so log lines of one session
+// can be correlated, but it cannot be used to hijack the session.
+func RedactSessionID(sessionID string) string {
+	return sha256Hex(sessionID)[:12]
+}
+
+// sessionAttr returns the log attribute identifying a session.
+func sessionAttr(sessionID string) slog.Attr {
+	return slog.String("session", RedactSessionID(sessionID))
+}
+
+// discardLogger drops every record. It stands in for unset loggers.
+var discardLogger = slog.New(discardHandler{})
+
+// discardHandler is a slog.Handler that is never enabled.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// loggerOrDiscard returns logger, or a logger discarding everything when it
+// is nil.
+func loggerOrDiscard(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return discardLogger
+	}
+	return logger
+}
+
+// logBackendError logs a failed store operation. Unknown and expired
+// sessions and rejected logins are expected outcomes and are not logged.
+func logBackendError(logger *slog.Logger, operation string, err error) {
+	if err == nil || errors.Is(err, ErrSessionNotFound) || errors.Is(err, ErrSessionExpired) ||
+		errors.Is(err, ErrSessionLimitReached) {
+		return
+	}
+	loggerOrDiscard(logger).Error("session store operation failed", slog.String("operation", operation), slog.Any("error", err))
+}
@@ -0,0 +1,124 @@
+package session
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestLogger returns a logger writing every level to buf.
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+}
+
+func TestRedactSessionID(t *testing.T) {
+	ref := RedactSessionID("secret-session-id")
+	if ref == RedactSessionID("other-session-id") {
+		t.Error("expected different sessions to have different references")
+	}
+	if ref != RedactSessionID("secret-session-id") {
+		t.Error("expected references to be stable")
+	}
+	if strings.Contains(ref, "secret") || len(ref) != 12 {
+		t.Errorf("unexpected reference %q", ref)
+	}
+}
+
+func TestLogging_RedactsSessionIDs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newTestLogger(&buf)
+
+	store := NewInMemorySessionStore()
+	store.Logger = logger
+	expired, _ := store.CreateSession("user1", -time.Minute)
+	bound, _ := store.CreateSession("user2", time.Hour)
+	bound.UserAgentHash = sha256Hex("original agent")
+	_ = store.UpdateSession(bound)
+
+	s := &Session{
+		Store:   store,
+		Logger:  logger,
+		Binding: ClientBinding{UserAgent: true, Policy: BindingLogOnly},
+	}
+	handler := s.ValidateSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name      string
+		sessionID string
+		expect    string
+	}{
+		{"expired session", expired.ID, `msg="session expired"`},
+		{"rejected session", expired.ID, `msg="session rejected"`},
+		{"binding mismatch", bound.ID, `msg="client binding mismatch"`},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: CookieName, Value: tt.sessionID})
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if err := store.CleanupExpiredSessions(); err != nil {
+		t.Fatalf("CleanupExpiredSessions() error = %v", err)
+	}
+
+	logs := buf.String()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(logs, tt.expect+" session="+RedactSessionID(tt.sessionID)) {
+				t.Errorf("expected %s with redacted session, got:\n%s", tt.expect, logs)
+			}
+		})
+	}
+	if !strings.Contains(logs, `msg="expired sessions cleaned up" deleted=1`) {
+		t.Errorf("expected cleanup run to be logged, got:\n%s", logs)
+	}
+	for _, id := range []string{expired.ID, bound.ID} {
+		if strings.Contains(logs, id) {
+			t.Errorf("raw session ID leaked into logs:\n%s", logs)
+		}
+	}
+}
+
+func TestDBSessionStore_LogsBackendErrors(t *testing.T) {
+	var buf bytes.Buffer
+	store := setupTestDB(t)
+	store.Logger = newTestLogger(&buf)
+
+	if _, err := store.GetSession("unknown"); err == nil {
+		t.Fatal("expected unknown session to fail")
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected unknown session not to be logged, got:\n%s", buf.String())
+	}
+
+	_ = store.db.Close()
+	if err := store.DeleteSession("id"); err == nil {
+		t.Fatal("expected DeleteSession() on a closed database to fail")
+	}
+	if !strings.Contains(buf.String(), `level=ERROR msg="session store operation failed" operation=delete`) {
+		t.Errorf("expected backend error to be logged, got:\n%s", buf.String())
+	}
+}
+
+func TestLogging_NilLoggerDiscards(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(newTestLogger(&buf))
+	defer slog.SetDefault(previous)
+
+	store := NewInMemorySessionStore()
+	expired, _ := store.CreateSession("user1", -time.Minute)
+	s := &Session{Store: store}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: CookieName, Value: expired.ID})
+	s.ValidateSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+
+	if buf.Len() != 0 {
+		t.Errorf("expected nothing to be logged without a Logger, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,326 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// metricsContentType is the content type of the Prometheus text exposition format.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// latencyBuckets are the upper bounds, in seconds, of the store latency histograms.
+var latencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// Rejection reasons reported by the session_rejections_total counter.
+const (
+	reasonMissingToken    = "missing_token"
+	reasonNotFound        = "not_found"
+	reasonExpired         = "expired"
+	reasonBindingMismatch = "binding_mismatch"
+	reasonTenantMismatch  = "tenant_mismatch"
+	reasonInvalid         = "invalid"
+)
+
+// Metrics collects session activity and exposes it in the Prometheus text
+// exposition format. Store activity is recorded by a store wrapped with
+// InstrumentStore, validations by a Session whose Metrics field is set.
+type Metrics struct {
+	mutex            sync.Mutex
+	created          uint64
+	validations      uint64
+	rejections       map[string]uint64
+	expirations      uint64
+	cleanupDeletions uint64
+	storeErrors      map[string]uint64
+	latency          map[string]*histogram
+
+	// active reports the active sessions gauge. It is set by InstrumentStore
+	// when the wrapped store implements ActiveSessionCounter.
+	active ActiveSessionCounter
+}
+
+// NewMetrics creates an empty set of session metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		rejections:  make(map[string]uint64),
+		storeErrors: make(map[string]uint64),
+		latency:     make(map[string]*histogram),
+	}
+}
+
+// histogram counts observations into cumulative latency buckets.
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func (h *histogram) observe(seconds float64) {
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// observeValidation records the outcome of a session validation. It is a
+// no-op on a nil Metrics so callers need not check whether metrics are enabled.
+func (m *Metrics) observeValidation(err error) {
+	if m == nil {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.validations++
+	if err != nil {
+		m.rejections[rejectionReason(err)]++
+	}
+}
+
+// observeStore records the latency and outcome of a store operation.
+func (m *Metrics) observeStore(operation string, start time.Time, err error) {
+	elapsed := time.Since(start).Seconds()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	h, ok := m.latency[operation]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(latencyBuckets))}
+		m.latency[operation] = h
+	}
+	h.observe(elapsed)
+
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrSessionExpired):
+		m.expirations++
+	case errors.Is(err, ErrSessionNotFound):
+	default:
+		m.storeErrors[operation]++
+	}
+}
+
+// rejectionReason classifies why a session was rejected.
+func rejectionReason(err error) string {
+	switch {
+	case errors.Is(err, errNoToken):
+		return reasonMissingToken
+	case errors.Is(err, ErrSessionExpired):
+		return reasonExpired
+	case errors.Is(err, ErrSessionNotFound):
+		return reasonNotFound
+	case errors.Is(err, errBindingMismatch):
+		return reasonBindingMismatch
+	case errors.Is(err, errTenantMismatch):
+		return reasonTenantMismatch
+	default:
+		return reasonInvalid
+	}
+}
+
+// Handler returns a handler serving the metrics in the Prometheus text
+// exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", metricsContentType)
+		m.writeTo(w)
+	})
+}
+
+// writeTo writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) writeTo(w io.Writer) {
+	m.mutex.Lock()
+	counter := m.active
+	m.mutex.Unlock()
+
+	// Count active sessions without holding the lock, so a slow store does
+	// not block the recording of other metrics.
+	active, activeOK := 0, false
+	if counter != nil {
+		count, err := counter.CountActiveSessions()
+		active, activeOK = count, err == nil
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	writeCounter(w, "session_created_total", "Sessions created.", m.created)
+	writeCounter(w, "session_validations_total", "Session validations by the middleware.", m.validations)
+	writeLabeledCounter(w, "session_rejections_total", "Sessions rejected by the middleware, by reason.", "reason", m.rejections)
+	writeCounter(w, "session_expirations_total", "Sessions found expired on lookup.", m.expirations)
+	writeCounter(w, "session_cleanup_deletions_total", "Expired sessions deleted by cleanup.", m.cleanupDeletions)
+	writeLabeledCounter(w, "session_store_errors_total", "Failed store operations, by operation.", "operation", m.storeErrors)
+
+	if activeOK {
+		fmt.Fprintf(w, "# HELP session_active Unexpired sessions in the store.\n")
+		fmt.Fprintf(w, "# TYPE session_active gauge\n")
+		fmt.Fprintf(w, "session_active %d\n", active)
+	}
+
+	const name = "session_store_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Latency of store operations.\n", name)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	for _, operation := range sortedKeys(m.latency) {
+		h := m.latency[operation]
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "%s_bucket{operation=%q,le=%q} %d\n", name, operation, formatFloat(bound), h.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{operation=%q,le=\"+Inf\"} %d\n", name, operation, h.count)
+		fmt.Fprintf(w, "%s_sum{operation=%q} %s\n", name, operation, formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count{operation=%q} %d\n", name, operation, h.count)
+	}
+}
+
+func writeCounter(w io.Writer, name, help string, value uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", name)
+	fmt.Fprintf(w, "%s %d\n", name, value)
+}
+
+func writeLabeledCounter(w io.Writer, name, help, label string, values map[string]uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", name)
+	for _, key := range sortedKeys(values) {
+		fmt.Fprintf(w, "%s{%s=%q} %d\n", name, label, key, values[key])
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// InstrumentStore wraps a store so that its operations are recorded in
+// metrics. When the store implements ActiveSessionCounter, the active
+// sessions gauge is read from it on every scrape.
+func InstrumentStore(store SessionStore, metrics *Metrics) SessionStore {
+	if counter, ok := store.(ActiveSessionCounter); ok {
+		metrics.mutex.Lock()
+		metrics.active = counter
+		metrics.mutex.Unlock()
+	}
+	return &instrumentedStore{store: store, metrics: metrics}
+}
+
+// instrumentedStore is the store returned by InstrumentStore.
+type instrumentedStore struct {
+	store   SessionStore
+	metrics *Metrics
+}
+
+func (s *instrumentedStore) CreateSession(userID string, duration time.Duration) (*SessionData, error) {
+	session, _, err := s.CreateSessionEvicting(userID, duration)
+	return session, err
+}
+
+func (s *instrumentedStore) CreateSessionEvicting(userID string, duration time.Duration) (*SessionData, []*SessionData, error) {
+	start := time.Now()
+	session, evicted, err := createSessionEvicting(s.store, userID, duration)
+	s.metrics.observeStore("create", start, err)
+
+	if err == nil {
+		s.metrics.mutex.Lock()
+		s.metrics.created++
+		s.metrics.mutex.Unlock()
+	}
+	return session, evicted, err
+}
+
+func (s *instrumentedStore) GetSession(sessionID string) (*SessionData, error) {
+	start := time.Now()
+	session, err := s.store.GetSession(sessionID)
+	s.metrics.observeStore("get", start, err)
+	return session, err
+}
+
+func (s *instrumentedStore) UpdateSession(session *SessionData) error {
+	start := time.Now()
+	err := updateSession(s.store, session)
+	s.metrics.observeStore("update", start, err)
+	return err
+}
+
+func (s *instrumentedStore) TouchSession(sessionID string, activity SessionActivity) error {
+	start := time.Now()
+	err := touchSession(s.store, sessionID, activity)
+	s.metrics.observeStore("touch", start, err)
+	return err
+}
+
+func (s *instrumentedStore) DeleteSession(sessionID string) error {
+	start := time.Now()
+	err := s.store.DeleteSession(sessionID)
+	s.metrics.observeStore("delete", start, err)
+	return err
+}
+
+// CleanupExpiredSessions counts the deleted sessions when the wrapped store
+// implements ExpiredSessionPurger.
+func (s *instrumentedStore) CleanupExpiredSessions() error {
+	_, err := s.PurgeExpiredSessions()
+	return err
+}
+
+func (s *instrumentedStore) PurgeExpiredSessions() ([]*SessionData, error) {
+	start := time.Now()
+
+	var purged []*SessionData
+	var err error
+	if purger, ok := s.store.(ExpiredSessionPurger); ok {
+		purged, err = purger.PurgeExpiredSessions()
+	} else {
+		err = s.store.CleanupExpiredSessions()
+	}
+	s.metrics.observeStore("cleanup", start, err)
+
+	s.metrics.mutex.Lock()
+	s.metrics.cleanupDeletions += uint64(len(purged))
+	s.metrics.mutex.Unlock()
+	return purged, err
+}
+
+func (s *instrumentedStore) CountActiveSessions() (int, error) {
+	return countActiveSessions(s.store)
+}
+
+func (s *instrumentedStore) ListSessions(query SessionQuery) ([]*SessionData, error) {
+	start := time.Now()
+	sessions, err := listSessions(s.store, query)
+	s.metrics.observeStore("list", start, err)
+	return sessions, err
+}
+
+func (s *instrumentedStore) ImportSession(session *SessionData) error {
+	start := time.Now()
+	err := importSession(s.store, session)
+	s.metrics.observeStore("import", start, err)
+	return err
+}
+
+// ForTenant instruments the tenant view of the wrapped store, so the Session
+// keeps scoping requests to their tenant.
+func (s *instrumentedStore) ForTenant(tenant string) SessionStore {
+	if scoper, ok := s.store.(TenantScoper); ok {
+		return &instrumentedStore{store: scoper.ForTenant(tenant), metrics: s.metrics}
+	}
+	return s
+}
@@ -0,0 +1,109 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics_Exposition(t *testing.T) {
+	metrics := NewMetrics()
+	store := InstrumentStore(NewInMemorySessionStore(), metrics)
+	s := &Session{Store: store, Metrics: metrics}
+	handler := s.ValidateSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	active, _ := store.CreateSession("user1", time.Hour)
+	expired, _ := store.CreateSession("user2", -time.Minute)
+	purged, _ := store.CreateSession("user3", -time.Minute)
+
+	for _, token := range []string{active.ID, expired.ID, "unknown", ""} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if token != "" {
+			req.AddCookie(&http.Cookie{Name: CookieName, Value: token})
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if err := store.CleanupExpiredSessions(); err != nil {
+		t.Fatalf("CleanupExpiredSessions() error = %v", err)
+	}
+	if _, err := store.GetSession(purged.ID); err == nil {
+		t.Fatal("expected purged session to be gone")
+	}
+
+	rr := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if got := rr.Header().Get("Content-Type"); got != metricsContentType {
+		t.Errorf("expected content type %q, got %q", metricsContentType, got)
+	}
+
+	body := rr.Body.String()
+	for _, line := range []string{
+		"# TYPE session_created_total counter",
+		"session_created_total 3",
+		"session_validations_total 4",
+		`session_rejections_total{reason="expired"} 1`,
+		`session_rejections_total{reason="missing_token"} 1`,
+		`session_rejections_total{reason="not_found"} 1`,
+		"session_expirations_total 1",
+		"session_cleanup_deletions_total 2",
+		"# TYPE session_active gauge",
+		"session_active 1",
+		"# TYPE session_store_duration_seconds histogram",
+		`session_store_duration_seconds_bucket{operation="create",le="+Inf"} 3`,
+		`session_store_duration_seconds_count{operation="get"} 4`,
+		`session_store_duration_seconds_count{operation="cleanup"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected exposition to contain %q, got:\n%s", line, body)
+		}
+	}
+}
+
+func TestRejectionReason(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		expect string
+	}{
+		{"missing token", httpError{cause: errNoToken}, reasonMissingToken},
+		{"unknown session", httpError{cause: ErrSessionNotFound}, reasonNotFound},
+		{"expired session", httpError{cause: ErrSessionExpired}, reasonExpired},
+		{"binding mismatch", httpError{cause: errBindingMismatch}, reasonBindingMismatch},
+		{"tenant mismatch", httpError{cause: errTenantMismatch}, reasonTenantMismatch},
+		{"other", httpError{}, reasonInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rejectionReason(tt.err); got != tt.expect {
+				t.Errorf("rejectionReason() = %q, want %q", got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestStores_PurgeAndCount(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store SessionStore) {
+		_, _ = store.CreateSession("user1", time.Hour)
+		expired, _ := store.CreateSession("user2", -time.Minute)
+
+		count, err := store.(ActiveSessionCounter).CountActiveSessions()
+		if err != nil || count != 1 {
+			t.Errorf("CountActiveSessions() = %d, %v, want 1", count, err)
+		}
+
+		purged, err := store.(ExpiredSessionPurger).PurgeExpiredSessions()
+		if err != nil {
+			t.Fatalf("PurgeExpiredSessions() error = %v", err)
+		}
+		if len(purged) != 1 || purged[0].ID != expired.ID {
+			t.Errorf("expected only the expired session to be purged, got %v", purged)
+		}
+	})
+}
@@ -0,0 +1,85 @@
+package otelsession
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ManuL3/sessions/session"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	store := session.TraceStore(session.NewInMemorySessionStore(), NewTracer(provider.Tracer("test")))
+
+	created, err := store.CreateSession("user1", 0)
+	if err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	updater := store.(session.SessionUpdater)
+	_ = updater.UpdateSession(created)
+	_ = store.DeleteSession(created.ID)
+	if err := updater.UpdateSession(created); err == nil {
+		t.Fatal("expected update of a deleted session to fail")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 4 {
+		t.Fatalf("expected 4 spans, got %d", len(spans))
+	}
+
+	tests := []struct {
+		name       string
+		span       sdktrace.ReadOnlySpan
+		expectName string
+		expectAttr attribute.KeyValue
+		expectCode codes.Code
+	}{
+		{"create", spans[0], "session.store.create", attribute.String(session.AttrOperation, "create"), codes.Unset},
+		{"delete", spans[2], "session.store.delete", attribute.String(session.AttrOperation, "delete"), codes.Unset},
+		{"failed update", spans[3], "session.store.update", attribute.String(session.AttrErrorKind, "not_found"), codes.Unset},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.span.Name() != tt.expectName {
+				t.Errorf("expected span %q, got %q", tt.expectName, tt.span.Name())
+			}
+			found := false
+			for _, attr := range tt.span.Attributes() {
+				if attr == tt.expectAttr {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("expected attribute %v, got %v", tt.expectAttr, tt.span.Attributes())
+			}
+			if tt.span.Status().Code != tt.expectCode {
+				t.Errorf("expected status %v, got %v", tt.expectCode, tt.span.Status().Code)
+			}
+		})
+	}
+}
+
+func TestSpan_RecordError(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	_, span := NewTracer(provider.Tracer("test")).Start(context.Background(), "op")
+	span.SetAttributes(session.Attribute{Key: session.AttrHit, Value: false})
+	span.RecordError(errors.New("database is locked"))
+	span.End()
+
+	ended := recorder.Ended()[0]
+	if ended.Status().Code != codes.Error || ended.Status().Description != "database is locked" {
+		t.Errorf("unexpected status %v", ended.Status())
+	}
+	if got := ended.Attributes(); len(got) != 1 || got[0] != attribute.Bool(session.AttrHit, false) {
+		t.Errorf("unexpected attributes %v", got)
+	}
+}
@@ -0,0 +1,410 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultSnapshotInterval is the time between two snapshots of a persistent
+// InMemorySessionStore when Persistence.SnapshotInterval is not set.
+const DefaultSnapshotInterval = 5 * time.Minute
+
+// Files kept in Persistence.Dir.
+const (
+	snapshotFile    = "sessions.snapshot"
+	changeLogFile   = "sessions.log"
+	rotatedLogFile  = "sessions.log.1"
+	snapshotFormat  = "sessions-snapshot"
+	snapshotVersion = 1
+)
+
+// Operations recorded in the change log.
+const (
+	opPutSession       = "put_session"
+	opDeleteSession    = "delete_session"
+	opPutToken         = "put_token"
+	opDeleteToken      = "delete_token"
+	opDeleteUserTokens = "delete_user_tokens"
+)
+
+// Persistence configures an InMemorySessionStore that survives restarts.
+type Persistence struct {
+	// Dir holds the snapshot and the change log. It is created if needed
+	// and must not be shared between stores.
+	Dir string
+
+	// SnapshotInterval is the time between two snapshots. Zero means
+	// DefaultSnapshotInterval.
+	SnapshotInterval time.Duration
+}
+
+// changeEntry is a line of the change log. Snapshots are written in the
+// same format, as a put for every session and remember-me token.
+type changeEntry struct {
+	Op      string        `json:"op"`
+	Session *exportRecord `json:"session,omitempty"`
+	Token   *tokenRecord  `json:"token,omitempty"`
+	ID      string        `json:"id,omitempty"`
+	UserID  string        `json:"user_id,omitempty"`
+	Tenant  string        `json:"tenant,omitempty"`
+}
+
+// tokenRecord is a remember-me token as written to disk.
+type tokenRecord struct {
+	Selector      string    `json:"selector"`
+	ValidatorHash string    `json:"validator_hash"`
+	UserID        string    `json:"user_id"`
+	Tenant        string    `json:"tenant,omitempty"`
+	ExpiresAt     time.Time `json:"expires_at"`
+
+	PreviousValidatorHash string    `json:"previous_validator_hash,omitempty"`
+	RotatedAt             time.Time `json:"rotated_at"`
+}
+
+// persister writes the changes of a store to disk.
+type persister struct {
+	dir     string
+	log     *os.File
+	encoder *json.Encoder
+
+	// snapshotMutex lets one snapshot run at a time.
+	snapshotMutex sync.Mutex
+
+	// closed is set by Close, under the store lock.
+	closed bool
+
+	closeOnce sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// OpenInMemorySessionStore creates an InMemorySessionStore that restores its
+// sessions and remember-me tokens from p.Dir. Changes are appended to a log
+// as they happen, and the whole store is written to a snapshot every
+// p.SnapshotInterval and by Close, which truncates the log. Restarting the
+// process therefore keeps users logged in. Changes reach the operating
+// system immediately but are not synced to disk, so they survive a crash of
+// the process, not of the machine.
+func OpenInMemorySessionStore(p Persistence) (*InMemorySessionStore, error) {
+	if err := os.MkdirAll(p.Dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	s := NewInMemorySessionStore()
+	for _, name := range []string{snapshotFile, rotatedLogFile, changeLogFile} {
+		if err := s.replay(filepath.Join(p.Dir, name)); err != nil {
+			return nil, fmt.Errorf("restoring %s: %w", name, err)
+		}
+	}
+
+	persist := &persister{dir: p.Dir, stop: make(chan struct{}), done: make(chan struct{})}
+	if err := persist.openLog(); err != nil {
+		return nil, err
+	}
+	s.persist = persist
+
+	// Compact what was restored, so the log starts out empty.
+	if err := s.Snapshot(); err != nil {
+		persist.log.Close()
+		return nil, err
+	}
+
+	interval := p.SnapshotInterval
+	if interval <= 0 {
+		interval = DefaultSnapshotInterval
+	}
+	go s.snapshotEvery(interval)
+
+	return s, nil
+}
+
+// Close writes a final snapshot and stops persisting the store. It does
+// nothing for stores created by NewInMemorySessionStore.
+func (s *InMemorySessionStore) Close() error {
+	p := s.persist
+	if p == nil {
+		return nil
+	}
+
+	var err error
+	p.closeOnce.Do(func() {
+		close(p.stop)
+		<-p.done
+
+		err = s.Snapshot()
+
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		p.closed = true
+		if closeErr := p.log.Close(); err == nil {
+			err = closeErr
+		}
+	})
+	return err
+}
+
+func (s *InMemorySessionStore) snapshotEvery(interval time.Duration) {
+	defer close(s.persist.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.persist.stop:
+			return
+		case <-ticker.C:
+			if err := s.Snapshot(); err != nil {
+				loggerOrDiscard(s.Logger).Error("session snapshot failed", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// Snapshot writes the unexpired sessions and remember-me tokens to the
+// snapshot file and truncates the change log.
+func (s *InMemorySessionStore) Snapshot() error {
+	p := s.persist
+	if p == nil {
+		return errors.New("store is not persistent")
+	}
+
+	p.snapshotMutex.Lock()
+	defer p.snapshotMutex.Unlock()
+
+	// Copy the store and start a new log in one step, so that every change
+	// is either in the snapshot or in the new log.
+	s.mutex.Lock()
+	if p.closed {
+		s.mutex.Unlock()
+		return errors.New("store is closed")
+	}
+	now := time.Now()
+	var entries []changeEntry
+	for _, session := range s.sessions {
+		if !session.ExpiresAt.Before(now) {
+			entries = append(entries, putSessionEntry(session.clone()))
+		}
+	}
+	for _, token := range s.rememberTokens {
+		if !token.ExpiresAt.Before(now) {
+			entries = append(entries, putTokenEntry(token))
+		}
+	}
+	err := p.rotateLog()
+	s.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := p.writeSnapshot(entries); err != nil {
+		return err
+	}
+
+	// The rotated log is part of the snapshot now.
+	if err := os.Remove(filepath.Join(p.dir, rotatedLogFile)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// record appends a change to the log. The caller must hold the lock, so
+// that changes are logged in the order they are applied. Failing to log a
+// change does not fail it: the store keeps working from memory.
+func (s *InMemorySessionStore) record(entry changeEntry) {
+	if s.persist == nil || s.persist.closed {
+		return
+	}
+	if err := s.persist.encoder.Encode(entry); err != nil {
+		loggerOrDiscard(s.Logger).Error("writing session change log failed", slog.Any("error", err))
+	}
+}
+
+func putSessionEntry(session *SessionData) changeEntry {
+	record := newExportRecord(session)
+	return changeEntry{Op: opPutSession, Session: &record}
+}
+
+func putTokenEntry(token *RememberToken) changeEntry {
+	return changeEntry{Op: opPutToken, Token: &tokenRecord{
+		Selector:      token.Selector,
+		ValidatorHash: token.ValidatorHash,
+		UserID:        token.UserID,
+		Tenant:        token.Tenant,
+		ExpiresAt:     token.ExpiresAt,
+
+		PreviousValidatorHash: token.PreviousValidatorHash,
+		RotatedAt:             token.RotatedAt,
+	}}
+}
+
+// replay applies the entries of a snapshot or log file to the store. A
+// missing file is empty. A last line without newline was cut off by a crash
+// and is ignored.
+func (s *InMemorySessionStore) replay(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(bytes.TrimSpace(data)) > 0 {
+				loggerOrDiscard(s.Logger).Warn("ignoring incomplete session change", slog.String("file", path))
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if line == 1 && filepath.Base(path) == snapshotFile {
+			var header exportHeader
+			if err := json.Unmarshal(data, &header); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			if header.Format != snapshotFormat || header.Version != snapshotVersion {
+				return fmt.Errorf("unsupported snapshot %s version %d", header.Format, header.Version)
+			}
+			continue
+		}
+
+		var entry changeEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := s.apply(entry); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
+
+// apply replays a single change without logging it.
+func (s *InMemorySessionStore) apply(entry changeEntry) error {
+	switch {
+	case entry.Op == opPutSession && entry.Session != nil:
+		s.sessions[entry.Session.ID] = entry.Session.session()
+	case entry.Op == opDeleteSession:
+		delete(s.sessions, entry.ID)
+	case entry.Op == opPutToken && entry.Token != nil:
+		s.rememberTokens[entry.Token.Selector] = &RememberToken{
+			Selector:      entry.Token.Selector,
+			ValidatorHash: entry.Token.ValidatorHash,
+			UserID:        entry.Token.UserID,
+			Tenant:        entry.Token.Tenant,
+			ExpiresAt:     entry.Token.ExpiresAt,
+
+			PreviousValidatorHash: entry.Token.PreviousValidatorHash,
+			RotatedAt:             entry.Token.RotatedAt,
+		}
+	case entry.Op == opDeleteToken:
+		delete(s.rememberTokens, entry.ID)
+	case entry.Op == opDeleteUserTokens:
+		for selector, token := range s.rememberTokens {
+			if token.UserID == entry.UserID && token.Tenant == entry.Tenant {
+				delete(s.rememberTokens, selector)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown change %q", entry.Op)
+	}
+	return nil
+}
+
+func (p *persister) openLog() error {
+	file, err := os.OpenFile(filepath.Join(p.dir, changeLogFile), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	p.log = file
+	p.encoder = json.NewEncoder(file)
+	return nil
+}
+
+// rotateLog moves the current log aside and starts a new one. If an earlier
+// snapshot failed, its rotated log is still needed and the current log is
+// appended to it instead. The caller must hold the store lock.
+func (p *persister) rotateLog() (err error) {
+	if err := p.log.Close(); err != nil {
+		return err
+	}
+	// Whatever happens, the store needs a log to write to.
+	defer func() {
+		if openErr := p.openLog(); err == nil {
+			err = openErr
+		}
+	}()
+
+	current := filepath.Join(p.dir, changeLogFile)
+	rotated := filepath.Join(p.dir, rotatedLogFile)
+	if _, err := os.Stat(rotated); errors.Is(err, fs.ErrNotExist) {
+		return os.Rename(current, rotated)
+	}
+
+	data, err := os.ReadFile(current)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(rotated, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(current)
+}
+
+// writeSnapshot replaces the snapshot file atomically.
+func (p *persister) writeSnapshot(entries []changeEntry) error {
+	path := filepath.Join(p.dir, snapshotFile)
+	file, err := os.CreateTemp(p.dir, snapshotFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	err = encoder.Encode(exportHeader{Format: snapshotFormat, Version: snapshotVersion, ExportedAt: time.Now()})
+	for _, entry := range entries {
+		if err != nil {
+			break
+		}
+		err = encoder.Encode(entry)
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
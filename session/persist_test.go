@@ -0,0 +1,137 @@
+package session
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T, dir string) *InMemorySessionStore {
+	t.Helper()
+
+	store, err := OpenInMemorySessionStore(Persistence{Dir: dir})
+	if err != nil {
+		t.Fatalf("OpenInMemorySessionStore() error = %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func TestInMemorySessionStore_Persistence(t *testing.T) {
+	tests := []struct {
+		name string
+		// restart ends the first process.
+		restart func(store *InMemorySessionStore) error
+	}{
+		{"clean shutdown", func(store *InMemorySessionStore) error { return store.Close() }},
+		// Without Close, the state is restored from the change log.
+		{"crash", func(store *InMemorySessionStore) error { return nil }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			store := openTestStore(t, dir)
+
+			kept, _ := store.CreateSession("user1", time.Hour)
+			kept.Device = "Work laptop"
+			_ = store.UpdateSession(kept)
+			_ = store.TouchSession(kept.ID, SessionActivity{SeenAt: time.Now(), IP: "203.0.113.7"})
+			deleted, _ := store.CreateSession("user2", time.Hour)
+			_ = store.DeleteSession(deleted.ID)
+			_ = store.SaveRememberToken(&RememberToken{Selector: "sel", ValidatorHash: "hash", UserID: "user1", ExpiresAt: time.Now().Add(time.Hour)})
+
+			if err := tt.restart(store); err != nil {
+				t.Fatalf("restart error = %v", err)
+			}
+
+			restored := openTestStore(t, dir)
+			got, err := restored.GetSession(kept.ID)
+			if err != nil {
+				t.Fatalf("GetSession() after restart error = %v", err)
+			}
+			if got.Device != "Work laptop" || got.LastIP != "203.0.113.7" {
+				t.Errorf("restored session lost changes: %+v", got)
+			}
+			if _, err := restored.GetSession(deleted.ID); !errors.Is(err, ErrSessionNotFound) {
+				t.Errorf("expected deleted session to stay deleted, got %v", err)
+			}
+			if _, err := restored.GetRememberToken("sel"); err != nil {
+				t.Errorf("GetRememberToken() after restart error = %v", err)
+			}
+		})
+	}
+}
+
+func TestInMemorySessionStore_ReplaysDeleteUserTokensInTenant(t *testing.T) {
+	dir := t.TempDir()
+	store := openTestStore(t, dir)
+
+	for _, tenant := range []string{"acme", "globex"} {
+		_ = store.SaveRememberToken(&RememberToken{Selector: tenant, ValidatorHash: "hash", UserID: "user1", Tenant: tenant, ExpiresAt: time.Now().Add(time.Hour)})
+	}
+	_ = store.DeleteUserRememberTokens("acme", "user1")
+
+	// Without Close, the deletion is replayed from the change log.
+	restored := openTestStore(t, dir)
+	if _, err := restored.GetRememberToken("acme"); err == nil {
+		t.Error("expected the acme token to stay deleted")
+	}
+	if _, err := restored.GetRememberToken("globex"); err != nil {
+		t.Errorf("expected the globex token of the same user to survive the replay, got %v", err)
+	}
+}
+
+func TestInMemorySessionStore_SnapshotCompactsLog(t *testing.T) {
+	dir := t.TempDir()
+	store := openTestStore(t, dir)
+
+	_, _ = store.CreateSession("user1", time.Hour)
+	_, _ = store.CreateSession("user2", -time.Minute)
+	if err := store.Snapshot(); err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+
+	if info, err := os.Stat(filepath.Join(dir, changeLogFile)); err != nil || info.Size() != 0 {
+		t.Errorf("expected an empty change log after the snapshot, got %v, %v", info, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, rotatedLogFile)); !os.IsNotExist(err) {
+		t.Errorf("expected the rotated log to be removed, got %v", err)
+	}
+
+	_ = store.Close()
+	restored := openTestStore(t, dir)
+	if count, _ := restored.CountActiveSessions(); count != 1 {
+		t.Errorf("expected 1 restored session, got %d", count)
+	}
+	if all, _ := restored.ListSessions(SessionQuery{IncludeExpired: true}); len(all) != 1 {
+		t.Errorf("expected expired sessions to be left out of the snapshot, got %d", len(all))
+	}
+}
+
+func TestInMemorySessionStore_RestoreIgnoresTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	store := openTestStore(t, dir)
+	session, _ := store.CreateSession("user1", time.Hour)
+
+	// Simulate a crash in the middle of writing a change.
+	log, err := os.OpenFile(filepath.Join(dir, changeLogFile), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = log.WriteString(`{"op":"delete_session","id":"`)
+	_ = log.Close()
+
+	restored := openTestStore(t, dir)
+	if _, err := restored.GetSession(session.ID); err != nil {
+		t.Errorf("GetSession() after restart error = %v", err)
+	}
+
+	corrupt := t.TempDir()
+	_ = os.WriteFile(filepath.Join(corrupt, changeLogFile), []byte("{\"op\":\"unknown\"}\n"), 0o600)
+	if _, err := OpenInMemorySessionStore(Persistence{Dir: corrupt}); err == nil {
+		t.Error("expected an error for a corrupt change log")
+	}
+}
@@ -0,0 +1,328 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultRememberCookieName is the cookie remember-me tokens are stored in.
+	DefaultRememberCookieName = "remember_me"
+	// DefaultRememberDuration is the lifetime of a remember-me token.
+	DefaultRememberDuration = 30 * 24 * time.Hour
+	// DefaultRememberRotationGrace is the time a rotated validator stays valid.
+	DefaultRememberRotationGrace = 30 * time.Second
+)
+
+const (
+	rememberSelectorLength  = 12
+	rememberValidatorLength = 32
+)
+
+var (
+	// ErrRememberTokenInvalid is returned for malformed, unknown or expired remember-me tokens.
+	ErrRememberTokenInvalid = errors.New("invalid remember-me token")
+	// ErrRememberTokenTheft is returned when a remember-me token is presented
+	// with a validator that was already rotated away. All remember-me tokens
+	// of the user are revoked when this happens.
+	ErrRememberTokenTheft = errors.New("remember-me token reused")
+	// errRememberNotConfigured is returned when a remember-me token is requested without a RememberMe configuration.
+	errRememberNotConfigured = errors.New("remember-me is not configured")
+)
+
+// RememberToken is the stored form of a remember-me token. Only a hash of
+// the validator is kept, so a leaked table cannot be used to log in.
+type RememberToken struct {
+	Selector      string
+	ValidatorHash string
+	UserID        string
+	Tenant        string
+	ExpiresAt     time.Time
+
+	// PreviousValidatorHash is the hash of the validator that was rotated
+	// away at RotatedAt. It stays valid for RememberMe.RotationGrace, so that
+	// parallel requests sent with the old cookie are not taken for theft.
+	PreviousValidatorHash string
+	RotatedAt             time.Time
+}
+
+// RememberStore defines the storage of remember-me tokens. SaveRememberToken
+// inserts a token or replaces the one with the same selector.
+// DeleteUserRememberTokens only deletes the tokens of the user within tenant.
+type RememberStore interface {
+	SaveRememberToken(token *RememberToken) error
+	GetRememberToken(selector string) (*RememberToken, error)
+	DeleteRememberToken(selector string) error
+	DeleteUserRememberTokens(tenant, userID string) error
+}
+
+// RememberTokenRotator is implemented by remember-me stores that can rotate
+// a validator atomically. With other stores, parallel requests carrying the
+// same token may each rotate it, and all but one of the new validators are
+// lost.
+type RememberTokenRotator interface {
+	// RotateRememberToken replaces the stored token with the same selector
+	// by token, but only if the stored validator hash is still
+	// token.PreviousValidatorHash. It reports whether the token was replaced.
+	RotateRememberToken(token *RememberToken) (bool, error)
+}
+
+// RememberMe issues long-lived "remember me" tokens using a split-token
+// design: the public selector locates the token and the secret validator is
+// checked against its hash. The validator is rotated on every use.
+type RememberMe struct {
+	Store RememberStore
+
+	// Duration defaults to DefaultRememberDuration.
+	Duration time.Duration
+	// CookieName defaults to DefaultRememberCookieName.
+	CookieName string
+
+	// RotationGrace is the time the previous validator of a token is still
+	// accepted after rotation. It defaults to DefaultRememberRotationGrace;
+	// a negative value accepts only the current validator.
+	RotationGrace time.Duration
+}
+
+// Issue creates a remember-me token for userID and sets its cookie.
+func (m *RememberMe) Issue(w http.ResponseWriter, userID string) error {
+	return m.issue(w, userID, "")
+}
+
+// issue creates a remember-me token that only resumes sessions of tenant.
+func (m *RememberMe) issue(w http.ResponseWriter, userID, tenant string) error {
+	duration := m.Duration
+	if duration == 0 {
+		duration = DefaultRememberDuration
+	}
+
+	selector, err := randomToken(rememberSelectorLength)
+	if err != nil {
+		return err
+	}
+	validator, err := randomToken(rememberValidatorLength)
+	if err != nil {
+		return err
+	}
+
+	token := &RememberToken{
+		Selector:      selector,
+		ValidatorHash: sha256Hex(validator),
+		UserID:        userID,
+		Tenant:        tenant,
+		ExpiresAt:     time.Now().Add(duration),
+	}
+	if err := m.Store.SaveRememberToken(token); err != nil {
+		return err
+	}
+
+	m.setCookie(w, selector+":"+validator, token.ExpiresAt)
+	return nil
+}
+
+// Forget revokes the remember-me token of the request and clears its cookie.
+func (m *RememberMe) Forget(w http.ResponseWriter, r *http.Request) error {
+	m.setCookie(w, "", time.Unix(0, 0))
+
+	cookie, err := r.Cookie(m.cookieName())
+	if err != nil {
+		return nil
+	}
+
+	selector, _, ok := strings.Cut(cookie.Value, ":")
+	if !ok {
+		return nil
+	}
+	return m.Store.DeleteRememberToken(selector)
+}
+
+// consume validates a remember-me token and rotates its validator. It
+// returns the stored token and the new cookie value. The value is empty when
+// a parallel request rotated the token moments ago; the response to that
+// request carries the new cookie. Tokens of tenants outside scope are
+// rejected with errTenantMismatch before anything is changed. On
+// ErrRememberTokenTheft the revoked token is returned as well, naming the
+// user whose sessions are at risk.
+func (m *RememberMe) consume(scope tenantScope, value string) (*RememberToken, string, error) {
+	selector, validator, ok := strings.Cut(value, ":")
+	if !ok || selector == "" || validator == "" {
+		return nil, "", ErrRememberTokenInvalid
+	}
+
+	token, err := m.Store.GetRememberToken(selector)
+	if err != nil {
+		return nil, "", ErrRememberTokenInvalid
+	}
+
+	if scope.scoped && token.Tenant != scope.tenant {
+		return token, "", errTenantMismatch
+	}
+
+	if token.ExpiresAt.Before(time.Now()) {
+		_ = m.Store.DeleteRememberToken(selector)
+		return nil, "", ErrRememberTokenInvalid
+	}
+
+	hash := sha256Hex(validator)
+	if hashesEqual(hash, token.ValidatorHash) {
+		next, err := randomToken(rememberValidatorLength)
+		if err != nil {
+			return nil, "", err
+		}
+
+		rotated := *token
+		rotated.ValidatorHash = sha256Hex(next)
+		rotated.PreviousValidatorHash = token.ValidatorHash
+		rotated.RotatedAt = time.Now()
+		saved, err := m.rotate(&rotated)
+		if err != nil {
+			return nil, "", err
+		}
+		if saved {
+			return &rotated, selector + ":" + next, nil
+		}
+
+		// A parallel request rotated the token first.
+		if token, err = m.Store.GetRememberToken(selector); err != nil {
+			return nil, "", ErrRememberTokenInvalid
+		}
+	}
+
+	if hashesEqual(hash, token.PreviousValidatorHash) && time.Since(token.RotatedAt) <= m.rotationGrace() {
+		return token, "", nil
+	}
+
+	// A known selector with a wrong validator means an old, rotated token
+	// was replayed: either the user or an attacker holds a stolen copy.
+	if err := m.Store.DeleteUserRememberTokens(token.Tenant, token.UserID); err != nil {
+		return nil, "", err
+	}
+	return token, "", ErrRememberTokenTheft
+}
+
+// rotate saves a rotated token, atomically if the store supports it.
+func (m *RememberMe) rotate(token *RememberToken) (bool, error) {
+	if rotator, ok := m.Store.(RememberTokenRotator); ok {
+		return rotator.RotateRememberToken(token)
+	}
+	return true, m.Store.SaveRememberToken(token)
+}
+
+// resume starts a new session from the remember-me cookie of the request.
+func (s *Session) resume(w http.ResponseWriter, r *http.Request) (*SessionData, bool) {
+	m := s.RememberMe
+
+	cookie, err := r.Cookie(m.cookieName())
+	if err != nil || cookie.Value == "" {
+		return nil, false
+	}
+
+	scope := allTenants
+	if tenant, ok := TenantFromContext(r.Context()); ok {
+		scope = tenantScope{tenant: tenant, scoped: true}
+	}
+
+	token, value, err := m.consume(scope, cookie.Value)
+	if err != nil {
+		if errors.Is(err, ErrRememberTokenTheft) || errors.Is(err, errTenantMismatch) {
+			s.logger().Warn("remember-me token rejected", slog.Any("error", err))
+			s.auditRememberRejection(r, token, err)
+		}
+		if errors.Is(err, ErrRememberTokenTheft) {
+			if err := s.revokeUserSessions(r, token.Tenant, token.UserID); err != nil {
+				s.logger().Error("revoking sessions after remember-me theft failed", slog.Any("error", err))
+			}
+		}
+		m.setCookie(w, "", time.Unix(0, 0))
+		return nil, false
+	}
+	if value != "" {
+		m.setCookie(w, value, token.ExpiresAt)
+	}
+
+	sessionData, err := s.start(w, r, token.UserID, StartOptions{}, auditReasonRemembered)
+	if err != nil {
+		s.logger().Error("resuming session failed", slog.Any("error", err))
+		return nil, false
+	}
+	return sessionData, true
+}
+
+// revokeUserSessions deletes the sessions of a user within tenant. Whoever
+// used a stolen remember-me token first holds a session resumed from it, which
+// must not outlive the detection of the theft. The store must implement
+// SessionLister.
+func (s *Session) revokeUserSessions(r *http.Request, tenant, userID string) error {
+	store := s.storeFor(r, auditReasonRememberTheft)
+	sessions, err := listSessions(store, SessionQuery{UserID: userID, IncludeExpired: true})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, session := range sessions {
+		if session.Tenant == tenant {
+			errs = append(errs, store.DeleteSession(session.ID))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// auditRememberRejection records a stolen or misplaced remember-me token.
+func (s *Session) auditRememberRejection(r *http.Request, token *RememberToken, err error) {
+	event := AuditEvent{Type: AuditSessionTampered, Reason: auditReasonRememberTheft}
+	if errors.Is(err, errTenantMismatch) {
+		event.Reason = auditReasonCrossTenant
+	}
+	if token != nil {
+		event.UserID = token.UserID
+		event.Session = RedactSessionID(token.Selector)
+	}
+	s.audit(r, event)
+}
+
+func (m *RememberMe) rotationGrace() time.Duration {
+	if m.RotationGrace == 0 {
+		return DefaultRememberRotationGrace
+	}
+	return m.RotationGrace
+}
+
+func (m *RememberMe) cookieName() string {
+	if m.CookieName == "" {
+		return DefaultRememberCookieName
+	}
+	return m.CookieName
+}
+
+// setCookie writes the remember-me cookie; an expiry in the past clears it.
+func (m *RememberMe) setCookie(w http.ResponseWriter, value string, expires time.Time) {
+	cookie := newSessionCookie(value, expires)
+	cookie.Name = m.cookieName()
+	if expires.Before(time.Now()) {
+		cookie.MaxAge = -1
+	}
+
+	http.SetCookie(w, cookie)
+}
+
+// hashesEqual compares validator hashes in constant time. An empty stored
+// hash matches nothing.
+func hashesEqual(hash, stored string) bool {
+	return stored != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(stored)) == 1
+}
+
+// randomToken returns n random bytes encoded as URL-safe base64.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
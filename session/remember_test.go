@@ -0,0 +1,305 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// rememberCookie returns the remember-me cookie set on a response, if any.
+func rememberCookie(rr *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range rr.Result().Cookies() {
+		if cookie.Name == DefaultRememberCookieName {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestRememberMe_Consume(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store SessionStore) {
+		m := &RememberMe{Store: store.(RememberStore)}
+
+		rr := httptest.NewRecorder()
+		if err := m.Issue(rr, "user1"); err != nil {
+			t.Fatalf("Issue() error = %v", err)
+		}
+		original := rememberCookie(rr).Value
+
+		token, rotated, err := m.consume(allTenants, original)
+		if err != nil {
+			t.Fatalf("consume() error = %v", err)
+		}
+		if token.UserID != "user1" {
+			t.Errorf("consume() user = %v, want user1", token.UserID)
+		}
+		if rotated == original {
+			t.Error("expected validator to be rotated")
+		}
+
+		if _, _, err := m.consume(allTenants, "malformed"); !errors.Is(err, ErrRememberTokenInvalid) {
+			t.Errorf("consume() malformed error = %v, want %v", err, ErrRememberTokenInvalid)
+		}
+
+		// Shortly after rotation the original token is still accepted, without
+		// another rotation, as it may come from a parallel request.
+		if token, value, err := m.consume(allTenants, original); err != nil || token.UserID != "user1" || value != "" {
+			t.Errorf("consume() within grace = %v, %q, %v; want the token without a new value", token, value, err)
+		}
+
+		// Once the grace period is over, replaying the original token reveals
+		// theft and revokes the rotated one too.
+		m.RotationGrace = -1
+		if _, _, err := m.consume(allTenants, original); !errors.Is(err, ErrRememberTokenTheft) {
+			t.Fatalf("consume() replay error = %v, want %v", err, ErrRememberTokenTheft)
+		}
+		if _, _, err := m.consume(allTenants, rotated); !errors.Is(err, ErrRememberTokenInvalid) {
+			t.Errorf("consume() after theft error = %v, want %v", err, ErrRememberTokenInvalid)
+		}
+	})
+}
+
+func TestStores_RotateRememberToken(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store SessionStore) {
+		rotator := store.(RememberTokenRotator)
+		token := &RememberToken{Selector: "selector", ValidatorHash: "first", UserID: "user1", ExpiresAt: time.Now().Add(time.Hour)}
+		_ = store.(RememberStore).SaveRememberToken(token)
+
+		second := *token
+		second.ValidatorHash, second.PreviousValidatorHash, second.RotatedAt = "second", "first", time.Now()
+		if saved, err := rotator.RotateRememberToken(&second); err != nil || !saved {
+			t.Fatalf("RotateRememberToken() = %v, %v; want saved", saved, err)
+		}
+
+		// A parallel rotation from the same validator loses.
+		third := second
+		third.ValidatorHash = "third"
+		if saved, err := rotator.RotateRememberToken(&third); err != nil || saved {
+			t.Fatalf("RotateRememberToken() of a stale token = %v, %v; want not saved", saved, err)
+		}
+
+		stored, _ := store.(RememberStore).GetRememberToken("selector")
+		if stored.ValidatorHash != "second" || stored.PreviousValidatorHash != "first" || stored.RotatedAt.IsZero() {
+			t.Errorf("unexpected stored token %+v", stored)
+		}
+	})
+}
+
+func TestSession_RememberMeConcurrentResume(t *testing.T) {
+	store := NewInMemorySessionStore()
+	s := &Session{Store: store, RememberMe: &RememberMe{Store: store}}
+
+	login := httptest.NewRecorder()
+	_, _ = s.Start(login, httptest.NewRequest(http.MethodPost, "/login", nil), "user1", StartOptions{Remember: true})
+	remember := rememberCookie(login)
+
+	// The browser sends two requests with the same cookie at once.
+	var wg sync.WaitGroup
+	responses := make([]*httptest.ResponseRecorder, 2)
+	for i := range responses {
+		responses[i] = httptest.NewRecorder()
+		wg.Add(1)
+		go func(rr *httptest.ResponseRecorder) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(remember)
+			s.ValidateSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rr, req)
+		}(responses[i])
+	}
+	wg.Wait()
+
+	var rotated []*http.Cookie
+	for _, rr := range responses {
+		if rr.Code != http.StatusOK {
+			t.Fatalf("expected both requests to resume, got %d", rr.Code)
+		}
+		if cookie := rememberCookie(rr); cookie != nil {
+			rotated = append(rotated, cookie)
+		}
+	}
+	if len(rotated) != 1 {
+		t.Fatalf("expected exactly one rotated cookie, got %d", len(rotated))
+	}
+
+	// The rotated cookie survived: the parallel request was not taken for theft.
+	if _, _, err := s.RememberMe.consume(allTenants, rotated[0].Value); err != nil {
+		t.Errorf("consume() of the rotated cookie error = %v", err)
+	}
+}
+
+func TestRememberMe_Expired(t *testing.T) {
+	m := &RememberMe{Store: NewInMemorySessionStore(), Duration: -time.Minute}
+
+	rr := httptest.NewRecorder()
+	if err := m.Issue(rr, "user1"); err != nil {
+		t.Fatalf("Issue() error = %v", err)
+	}
+
+	if _, _, err := m.consume(allTenants, rememberCookie(rr).Value); !errors.Is(err, ErrRememberTokenInvalid) {
+		t.Errorf("consume() expired error = %v, want %v", err, ErrRememberTokenInvalid)
+	}
+}
+
+func TestSession_RememberMeResume(t *testing.T) {
+	store := NewInMemorySessionStore()
+	s := &Session{Store: store, RememberMe: &RememberMe{Store: store, RotationGrace: -1}}
+
+	login := httptest.NewRecorder()
+	sessionData, err := s.Start(login, httptest.NewRequest(http.MethodPost, "/login", nil), "user1", StartOptions{Remember: true})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	remember := rememberCookie(login)
+	if remember == nil {
+		t.Fatal("expected remember-me cookie to be issued")
+	}
+
+	// Simulate the short session running out.
+	_ = store.DeleteSession(sessionData.ID)
+
+	tests := []struct {
+		name         string
+		remember     string
+		expectCode   int
+		expectResume bool
+	}{
+		{"resumes from remember-me cookie", remember.Value, http.StatusOK, true},
+		{"replayed remember-me cookie", remember.Value, http.StatusUnauthorized, false},
+		{"no remember-me cookie", "", http.StatusUnauthorized, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(&http.Cookie{Name: CookieName, Value: sessionData.ID})
+			if tt.remember != "" {
+				req.AddCookie(&http.Cookie{Name: DefaultRememberCookieName, Value: tt.remember})
+			}
+
+			var userID string
+			rr := httptest.NewRecorder()
+			s.ValidateSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				userID, _ = UserIDFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectCode {
+				t.Fatalf("expected code %d, got %d", tt.expectCode, rr.Code)
+			}
+			if !tt.expectResume {
+				return
+			}
+
+			if userID != "user1" {
+				t.Errorf("expected resumed session for user1, got %q", userID)
+			}
+			if cookie := rememberCookie(rr); cookie == nil || cookie.Value == tt.remember {
+				t.Error("expected rotated remember-me cookie")
+			}
+		})
+	}
+}
+
+func TestSession_RememberMeTheftRevokesSessions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store SessionStore) {
+		s := &Session{Store: store, RememberMe: &RememberMe{Store: store.(RememberStore), RotationGrace: -1}}
+		validate := s.ValidateSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		login := httptest.NewRecorder()
+		_, _ = s.Start(login, httptest.NewRequest(http.MethodPost, "/login", nil), "user1", StartOptions{Remember: true})
+		stolen := rememberCookie(login)
+		other, _ := store.CreateSession("user2", time.Hour)
+
+		// The thief resumes a session from the stolen cookie first.
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(stolen)
+		thief := httptest.NewRecorder()
+		validate.ServeHTTP(thief, req)
+		var resumed string
+		for _, cookie := range thief.Result().Cookies() {
+			if cookie.Name == CookieName {
+				resumed = cookie.Value
+			}
+		}
+		if thief.Code != http.StatusOK || resumed == "" {
+			t.Fatalf("expected the stolen cookie to resume a session, got %d", thief.Code)
+		}
+
+		// The user's copy of the cookie reveals the theft.
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(stolen)
+		validate.ServeHTTP(httptest.NewRecorder(), req)
+
+		if _, err := store.GetSession(resumed); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("expected the resumed session to be revoked, got %v", err)
+		}
+		if _, err := store.GetSession(other.ID); err != nil {
+			t.Errorf("expected sessions of other users to survive, got %v", err)
+		}
+	})
+}
+
+func TestSession_LogoutForgetsRememberMe(t *testing.T) {
+	store := NewInMemorySessionStore()
+	s := &Session{Store: store, RememberMe: &RememberMe{Store: store}}
+
+	login := httptest.NewRecorder()
+	sessionData, _ := s.Start(login, httptest.NewRequest(http.MethodPost, "/login", nil), "user1", StartOptions{Remember: true})
+	remember := rememberCookie(login)
+
+	req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: CookieName, Value: sessionData.ID})
+	req.AddCookie(remember)
+
+	rr := httptest.NewRecorder()
+	if err := s.Logout(rr, req); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+
+	if cookie := rememberCookie(rr); cookie == nil || cookie.MaxAge >= 0 {
+		t.Error("expected remember-me cookie to be cleared")
+	}
+	if _, _, err := s.RememberMe.consume(allTenants, remember.Value); !errors.Is(err, ErrRememberTokenInvalid) {
+		t.Errorf("consume() after logout error = %v, want %v", err, ErrRememberTokenInvalid)
+	}
+}
+
+// failingRememberStore fails to delete remember-me tokens.
+type failingRememberStore struct {
+	*InMemorySessionStore
+}
+
+func (failingRememberStore) DeleteRememberToken(selector string) error {
+	return errors.New("storage unavailable")
+}
+
+func TestSession_LogoutRevokesSessionWhenForgetFails(t *testing.T) {
+	store := NewInMemorySessionStore()
+	s := &Session{Store: store, RememberMe: &RememberMe{Store: failingRememberStore{store}}}
+
+	login := httptest.NewRecorder()
+	sessionData, _ := s.Start(login, httptest.NewRequest(http.MethodPost, "/login", nil), "user1", StartOptions{Remember: true})
+
+	req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+	req.AddCookie(&http.Cookie{Name: CookieName, Value: sessionData.ID})
+	req.AddCookie(rememberCookie(login))
+
+	if err := s.Logout(httptest.NewRecorder(), req); err == nil {
+		t.Error("expected Logout() to report the failed remember-me revocation")
+	}
+	if _, err := store.GetSession(sessionData.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected the session to be revoked, got %v", err)
+	}
+}
+
+func TestSession_StartRememberWithoutConfiguration(t *testing.T) {
+	s := &Session{Store: NewInMemorySessionStore()}
+
+	_, err := s.Start(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/login", nil), "user1", StartOptions{Remember: true})
+	if err == nil {
+		t.Error("expected Start() to fail without RememberMe configured")
+	}
+}
@@ -0,0 +1,121 @@
+package session
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const forbiddenMessage = "Forbidden"
+
+// RoleLoader loads the current roles and scopes of a user.
+type RoleLoader interface {
+	LoadRoles(userID string) (roles []string, scopes []string, err error)
+}
+
+// RoleLoaderFunc adapts an ordinary function to the RoleLoader interface.
+type RoleLoaderFunc func(userID string) (roles []string, scopes []string, err error)
+
+// LoadRoles calls f(userID).
+func (f RoleLoaderFunc) LoadRoles(userID string) ([]string, []string, error) {
+	return f(userID)
+}
+
+// RequireRole returns a middleware that only lets requests through when the
+// session holds at least one of the given roles.
+func (s *Session) RequireRole(roles ...string) func(http.Handler) http.Handler {
+	return requireSession(func(sessionData *SessionData) bool {
+		for _, role := range roles {
+			if slices.Contains(sessionData.Roles, role) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// RequireScope returns a middleware that only lets requests through when the
+// session holds all of the given scopes.
+func (s *Session) RequireScope(scopes ...string) func(http.Handler) http.Handler {
+	return requireSession(func(sessionData *SessionData) bool {
+		for _, scope := range scopes {
+			if !slices.Contains(sessionData.Scopes, scope) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// requireSession builds a middleware that rejects requests without a
+// session with 401 and requests whose session fails allowed with 403.
+func requireSession(allowed func(sessionData *SessionData) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sessionData, ok := GetSessionFromContext(r.Context())
+			if !ok {
+				http.Error(w, unauthorizedMessage, http.StatusUnauthorized)
+				return
+			}
+
+			if !allowed(sessionData) {
+				http.Error(w, forbiddenMessage, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RolesFromContext returns the roles of the session in a context.
+func RolesFromContext(ctx context.Context) []string {
+	session, ok := GetSessionFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return session.Roles
+}
+
+// ScopesFromContext returns the scopes of the session in a context.
+func ScopesFromContext(ctx context.Context) []string {
+	session, ok := GetSessionFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	return session.Scopes
+}
+
+// HasRole reports whether the session in a context holds role.
+func HasRole(ctx context.Context, role string) bool {
+	return slices.Contains(RolesFromContext(ctx), role)
+}
+
+// HasScope reports whether the session in a context holds scope.
+func HasScope(ctx context.Context, scope string) bool {
+	return slices.Contains(ScopesFromContext(ctx), scope)
+}
+
+// refreshRoles reloads stale roles through the RoleLoader. When loading
+// fails, the previous roles are kept and loading is retried on the next
+// request.
+func (s *Session) refreshRoles(r *http.Request, sessionData *SessionData) {
+	if s.RoleLoader == nil || time.Since(sessionData.RolesLoadedAt) < s.RolesTTL {
+		return
+	}
+
+	roles, scopes, err := s.RoleLoader.LoadRoles(sessionData.UserID)
+	if err != nil {
+		s.logger().Warn("loading roles failed", sessionAttr(sessionData.ID), slog.Any("error", err))
+		return
+	}
+
+	sessionData.Roles = roles
+	sessionData.Scopes = scopes
+	sessionData.RolesLoadedAt = time.Now()
+	if err := updateSession(s.store(r), sessionData); err != nil {
+		s.logger().Warn("saving roles failed", sessionAttr(sessionData.ID), slog.Any("error", err))
+	}
+}
@@ -0,0 +1,115 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSession_RequireRoleAndScope(t *testing.T) {
+	s := &Session{}
+	sessionData := &SessionData{
+		Roles:  []string{"support"},
+		Scopes: []string{"orders:read", "orders:write"},
+	}
+
+	tests := []struct {
+		name       string
+		middleware func(http.Handler) http.Handler
+		session    *SessionData
+		expectCode int
+	}{
+		{"role held", s.RequireRole("admin", "support"), sessionData, http.StatusOK},
+		{"role missing", s.RequireRole("admin"), sessionData, http.StatusForbidden},
+		{"role without session", s.RequireRole("support"), nil, http.StatusUnauthorized},
+		{"all scopes held", s.RequireScope("orders:read", "orders:write"), sessionData, http.StatusOK},
+		{"scope missing", s.RequireScope("orders:read", "payouts:write"), sessionData, http.StatusForbidden},
+		{"scope without session", s.RequireScope("orders:read"), nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.session != nil {
+				req = req.WithContext(WithSession(req.Context(), tt.session))
+			}
+
+			rr := httptest.NewRecorder()
+			tt.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})).ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectCode {
+				t.Errorf("expected code %d, got %d", tt.expectCode, rr.Code)
+			}
+		})
+	}
+}
+
+func TestRoleContextHelpers(t *testing.T) {
+	ctx := WithSession(context.Background(), &SessionData{
+		Roles:  []string{"admin"},
+		Scopes: []string{"orders:read"},
+	})
+
+	if !HasRole(ctx, "admin") || HasRole(ctx, "support") {
+		t.Errorf("HasRole() with roles %v returned unexpected results", RolesFromContext(ctx))
+	}
+	if !HasScope(ctx, "orders:read") || HasScope(ctx, "orders:write") {
+		t.Errorf("HasScope() with scopes %v returned unexpected results", ScopesFromContext(ctx))
+	}
+	if HasRole(context.Background(), "admin") {
+		t.Error("HasRole() without session returned true")
+	}
+}
+
+func TestSession_RefreshRoles(t *testing.T) {
+	tests := []struct {
+		name        string
+		loadedAgo   time.Duration
+		loaderErr   error
+		expectRoles []string
+		expectCalls int
+	}{
+		{"fresh roles", time.Second, nil, []string{"user"}, 0},
+		{"stale roles", time.Hour, nil, []string{"admin"}, 1},
+		{"loader failure keeps roles", time.Hour, errors.New("directory unavailable"), []string{"user"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewInMemorySessionStore()
+			var calls int
+			s := &Session{
+				Store:    store,
+				RolesTTL: time.Minute,
+				RoleLoader: RoleLoaderFunc(func(userID string) ([]string, []string, error) {
+					calls++
+					return []string{"admin"}, []string{"orders:write"}, tt.loaderErr
+				}),
+			}
+
+			sessionData, _ := s.Start(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/login", nil),
+				"user1", StartOptions{Roles: []string{"user"}})
+			sessionData.RolesLoadedAt = time.Now().Add(-tt.loadedAgo)
+			_ = store.UpdateSession(sessionData)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(&http.Cookie{Name: CookieName, Value: sessionData.ID})
+			if _, err := s.validateAndFetchSession(req); err != nil {
+				t.Fatalf("validateAndFetchSession() error = %v", err)
+			}
+
+			stored, _ := store.GetSession(sessionData.ID)
+			if len(stored.Roles) != 1 || stored.Roles[0] != tt.expectRoles[0] {
+				t.Errorf("expected roles %v, got %v", tt.expectRoles, stored.Roles)
+			}
+			if calls != tt.expectCalls {
+				t.Errorf("expected %d loader calls, got %d", tt.expectCalls, calls)
+			}
+		})
+	}
+}
@@ -2,9 +2,13 @@ package session
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"log/slog"
 	"math/rand"
 	"net/http"
+	"net/netip"
 	"time"
 )
 
@@ -12,19 +16,105 @@ const (
 	CookieName = "session_id"
 )
 
+// DefaultSessionDuration is the session lifetime used by Start when
+// StartOptions.Duration is not set.
+const DefaultSessionDuration = 30 * time.Minute
+
+// DefaultTouchInterval is the minimum time between two activity updates of a
+// session when Session.TouchInterval is not set.
+const DefaultTouchInterval = time.Minute
+
 const (
 	unauthorizedMessage    = "Unauthorized access"
 	requestCanceledMessage = "Request canceled"
+	logoutFailedMessage    = "Logout failed"
 )
 
+// errNoToken is the cause of rejections of requests without a session token.
+var errNoToken = errors.New("no session token")
+
 type Session struct {
 	Store SessionStore
+
+	// Extractors locate the session token in a request, in priority order.
+	// When empty, the token is read from the CookieName cookie.
+	Extractors []TokenExtractor
+
+	// Binding ties new sessions to the client that created them.
+	Binding ClientBinding
+
+	// TrustedProxies lists the proxies whose X-Forwarded-For header is
+	// trusted when determining the client IP.
+	TrustedProxies []netip.Prefix
+
+	// TouchInterval throttles how often a session's activity is written to
+	// the store. Zero means DefaultTouchInterval; a negative value disables
+	// activity tracking.
+	TouchInterval time.Duration
+
+	// ReauthHandler is called by RequireAuthLevel when the session's
+	// authentication is too weak or too old. It defaults to a 401 response.
+	ReauthHandler http.Handler
+
+	// RoleLoader, when set, refreshes the roles and scopes of sessions whose
+	// roles were loaded longer than RolesTTL ago. A zero RolesTTL reloads
+	// them on every request.
+	RoleLoader RoleLoader
+	RolesTTL   time.Duration
+
+	// Impersonators decides who may call Impersonate. Without one, every
+	// impersonation is refused.
+	Impersonators AdminAuthorizer
+
+	// RememberMe, when set, resumes logins from remember-me tokens once the
+	// session has expired.
+	RememberMe *RememberMe
+
+	// Metrics, when set, counts validations and rejections by ValidateSession.
+	Metrics *Metrics
+
+	// Logger receives rejected sessions and failures that do not fail the
+	// request. Nil disables logging. Session IDs are never logged.
+	Logger *slog.Logger
+
+	// Audit, when set, receives the audit trail of the sessions handled by
+	// the controller.
+	Audit AuditSink
+
+	// Events, when set, receives a rotation event whenever a login
+	// replaces a live session.
+	Events *EventBus
+
+	// Tracer, when set, traces session validation and every store call made
+	// while handling a request.
+	Tracer Tracer
+}
+
+// StartOptions configures a session created by Start.
+type StartOptions struct {
+	// Duration is the session lifetime. Zero means DefaultSessionDuration.
+	Duration time.Duration
+
+	// AuthLevel is the authentication the user just completed.
+	AuthLevel AuthLevel
+
+	// Roles and Scopes are stored on the session for RequireRole and RequireScope.
+	Roles  []string
+	Scopes []string
+
+	// Device is an optional label for the session, such as "Work laptop".
+	Device string
+
+	// Remember issues a remember-me token along with the session. It
+	// requires Session.RememberMe to be set.
+	Remember bool
 }
 
 // httpError encapsulates an HTTP error response.
 type httpError struct {
 	message string
 	code    int
+	cause   error
 }
 
 // Error satisfies the error interface for httpError.
@@ -32,25 +122,178 @@ func (e httpError) Error() string {
 	return e.message
 }
 
+// Unwrap returns the underlying cause of the error, if any.
+func (e httpError) Unwrap() error {
+	return e.cause
+}
+
 // SetSessionCookie sets the cookie to the http response.
 func SetSessionCookie(sessionID string, w http.ResponseWriter) {
-	// Create a new cookie with the session ID
-	cookie := &http.Cookie{
-		Name:     CookieName,                         // Cookie name
-		Value:    sessionID,                          // Cookie value (session ID)
-		Path:     "/",                                // Path for which this cookie is valid
-		HttpOnly: true,                               // For security, to make it inaccessible to JS
-		Secure:   false,                              // Send only over HTTPS
-		Expires:  time.Now().Add(7 * 24 * time.Hour), // Cookie expiration (7 days in this case)
-	}
+	// Create a new cookie with the session ID, valid for 7 days
+	cookie := newSessionCookie(sessionID, time.Now().Add(7*24*time.Hour))
 
 	// Add the cookie to the HTTP response
 	http.SetCookie(w, cookie)
 }
 
+// ClearSessionCookie expires the session cookie on the client.
+func ClearSessionCookie(w http.ResponseWriter) {
+	cookie := newSessionCookie("", time.Unix(0, 0))
+	cookie.MaxAge = -1
+
+	http.SetCookie(w, cookie)
+}
+
+// newSessionCookie builds the session cookie so that setting and clearing it
+// always use the same attributes.
+func newSessionCookie(value string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     CookieName, // Cookie name
+		Value:    value,      // Cookie value (session ID)
+		Path:     "/",        // Path for which this cookie is valid
+		HttpOnly: true,       // For security, to make it inaccessible to JS
+		Secure:   false,      // Send only over HTTPS
+		Expires:  expires,    // Cookie expiration
+	}
+}
+
+// Start logs userID in. Any session already carried by the request is
+// revoked, a new session is created and the session cookie is set to expire
+// together with it.
+func (s *Session) Start(w http.ResponseWriter, r *http.Request, userID string, opts StartOptions) (*SessionData, error) {
+	return s.start(w, r, userID, opts, auditReasonLogin)
+}
+
+// start implements Start. The audit trail records reason as the cause of
+// the new session.
+func (s *Session) start(w http.ResponseWriter, r *http.Request, userID string, opts StartOptions, reason string) (*SessionData, error) {
+	if opts.Remember && s.RememberMe == nil {
+		return nil, errRememberNotConfigured
+	}
+
+	// A login replacing a live session is audited as a rotation.
+	previous, hasPrevious := s.extractToken(r)
+	rotated := false
+	if hasPrevious {
+		if s.Audit != nil || s.Events != nil {
+			_, err := s.store(r).GetSession(previous)
+			rotated = err == nil
+		}
+		if err := s.storeFor(r, auditReasonRotated).DeleteSession(previous); err != nil {
+			return nil, err
+		}
+	}
+
+	store := s.storeFor(r, reason)
+
+	duration := opts.Duration
+	if duration == 0 {
+		duration = DefaultSessionDuration
+	}
+
+	sessionData, err := store.CreateSession(userID, duration)
+	if err != nil {
+		return nil, err
+	}
+	createdTenant := sessionData.Tenant
+
+	if tenant, ok := TenantFromContext(r.Context()); ok {
+		sessionData.Tenant = tenant
+	}
+
+	sessionData.AuthLevel = opts.AuthLevel
+	sessionData.AuthenticatedAt = sessionData.CreatedAt
+	if opts.Roles != nil || opts.Scopes != nil {
+		sessionData.Roles = opts.Roles
+		sessionData.Scopes = opts.Scopes
+		sessionData.RolesLoadedAt = sessionData.CreatedAt
+	}
+	sessionData.Device = opts.Device
+	s.recordClient(r, sessionData)
+	if s.Binding.enabled() {
+		s.bindClient(r, sessionData)
+	}
+
+	// The client and the time of authentication are only recorded when the
+	// store supports updates. Anything else the session cannot do without,
+	// so a failed update revokes it rather than leaving it behind unused.
+	required := sessionData.Tenant != createdTenant || opts.AuthLevel != 0 || opts.Roles != nil ||
+		opts.Scopes != nil || opts.Device != "" || s.Binding.enabled()
+	if err := updateSession(store, sessionData); err != nil && (required || !errors.Is(err, ErrUpdateNotSupported)) {
+		return nil, errors.Join(err, store.DeleteSession(sessionData.ID))
+	}
+
+	http.SetCookie(w, newSessionCookie(sessionData.ID, sessionData.ExpiresAt))
+
+	if rotated {
+		s.Events.publish(SessionEvent{Type: SessionRotated, SessionID: sessionData.ID, Session: sessionData, PreviousID: previous})
+		s.audit(r, AuditEvent{
+			Type:     AuditSessionRotated,
+			Session:  RedactSessionID(sessionData.ID),
+			Previous: RedactSessionID(previous),
+			UserID:   userID,
+			Reason:   reason,
+		})
+	}
+
+	if opts.Remember {
+		if err := s.RememberMe.issue(w, userID, sessionData.Tenant); err != nil {
+			return nil, err
+		}
+	}
+
+	return sessionData, nil
+}
+
+// recordClient stores the client that created the session on it.
+func (s *Session) recordClient(r *http.Request, sessionData *SessionData) {
+	sessionData.UserAgent = r.UserAgent()
+	if addr, ok := ClientIP(r, s.TrustedProxies); ok {
+		sessionData.CreatedIP = addr.String()
+		sessionData.LastIP = sessionData.CreatedIP
+	}
+}
+
+// Logout revokes the session of the request, if any, and clears the session
+// cookie. The cookie is cleared even when deleting from the store fails.
+// A remember-me token is revoked as well; failing to revoke it does not keep
+// the session alive.
+func (s *Session) Logout(w http.ResponseWriter, r *http.Request) error {
+	ClearSessionCookie(w)
+
+	var err error
+	if token, ok := s.extractToken(r); ok {
+		err = s.storeFor(r, auditReasonLogout).DeleteSession(token)
+	}
+
+	if s.RememberMe != nil {
+		err = errors.Join(err, s.RememberMe.Forget(w, r))
+	}
+	return err
+}
+
+// LogoutHandler returns a handler that logs the user out. It redirects to
+// redirectTo when set and answers with 204 No Content otherwise.
+func (s *Session) LogoutHandler(redirectTo string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Logout(w, r); err != nil {
+			s.logger().Error("logout failed", slog.Any("error", err))
+			http.Error(w, logoutFailedMessage, http.StatusInternalServerError)
+			return
+		}
+
+		if redirectTo != "" {
+			http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
 func (s *Session) ValidateSession(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		sessionData, err := s.validateAndFetchSession(r)
+		sessionData, err := s.loadSession(w, r)
+		s.Metrics.observeValidation(err)
 		if err != nil {
 			handleHTTPError(w, err)
 			return
@@ -67,22 +310,178 @@ func (s *Session) ValidateSession(next http.Handler) http.Handler {
 	})
 }
 
+// LoadSession attaches the session to the request context when the request
+// carries a valid session cookie. Requests without one are passed on
+// anonymously and are never rejected.
+func (s *Session) LoadSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if sessionData, err := s.loadSession(w, r); err == nil {
+			r = r.WithContext(WithSession(r.Context(), sessionData))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LazyLoadSession works like LoadSession but defers the store lookup until a
+// handler first asks for the session, so requests that never look at it cost
+// nothing.
+func (s *Session) LazyLoadSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := withLazySession(r.Context(), func() (*SessionData, error) {
+			return s.loadSession(w, r)
+		})
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireSession rejects requests that have no session in their context.
+// It is meant to be layered on top of LoadSession.
+func (s *Session) RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := GetSessionFromContext(r.Context()); !ok {
+			http.Error(w, unauthorizedMessage, http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// loadSession loads the session of the request, within a validation span
+// when tracing is enabled.
+func (s *Session) loadSession(w http.ResponseWriter, r *http.Request) (*SessionData, error) {
+	var span Span
+	if s.Tracer != nil {
+		var ctx context.Context
+		ctx, span = s.Tracer.Start(r.Context(), spanValidate)
+		r = r.WithContext(ctx)
+	}
+
+	sessionData, err := s.fetchOrResume(w, r)
+	if token, ok := s.extractToken(r); ok && err != nil {
+		s.logger().Debug("session rejected", sessionAttr(token), slog.String("reason", rejectionReason(err)))
+	}
+
+	if span != nil {
+		span.SetAttributes(Attribute{AttrOperation, "validate"}, Attribute{AttrHit, err == nil})
+		if err != nil {
+			span.SetAttributes(Attribute{AttrErrorKind, rejectionReason(err)})
+		}
+		span.End()
+	}
+	return sessionData, err
+}
+
+// fetchOrResume validates the session of the request. When there is no valid
+// session, it tries to resume the login from a remember-me token instead.
+func (s *Session) fetchOrResume(w http.ResponseWriter, r *http.Request) (*SessionData, error) {
+	sessionData, err := s.validateAndFetchSession(r)
+	if err == nil || s.RememberMe == nil || errors.Is(err, errBindingMismatch) {
+		return sessionData, err
+	}
+
+	if resumed, ok := s.resume(w, r); ok {
+		return resumed, nil
+	}
+	return nil, err
+}
+
 // validateAndFetchSession validates the session and retrieves session data.
 func (s *Session) validateAndFetchSession(r *http.Request) (*SessionData, error) {
-	cookie, err := r.Cookie(CookieName)
-	if err != nil || cookie.Value == "" {
-		return nil, httpError{message: unauthorizedMessage, code: http.StatusUnauthorized}
+	token, ok := s.extractToken(r)
+	if !ok {
+		return nil, httpError{message: unauthorizedMessage, code: http.StatusUnauthorized, cause: errNoToken}
 	}
 
-	cookieValue := cookie.Value
-	sessionData, err := s.Store.GetSession(cookieValue)
-	if err != nil || sessionData.ExpiresAt.Before(time.Now()) {
-		return nil, httpError{message: unauthorizedMessage, code: http.StatusUnauthorized}
+	if !isSessionIDFormat(token) {
+		s.audit(r, AuditEvent{Type: AuditSessionTampered, Session: RedactSessionID(token), Reason: auditReasonMalformedToken})
 	}
 
+	sessionData, err := s.store(r).GetSession(token)
+	if err != nil {
+		return nil, httpError{message: unauthorizedMessage, code: http.StatusUnauthorized, cause: err}
+	}
+	if sessionData.ExpiresAt.Before(time.Now()) {
+		return nil, httpError{message: unauthorizedMessage, code: http.StatusUnauthorized, cause: ErrSessionExpired}
+	}
+
+	if err := checkTenant(r.Context(), sessionData); err != nil {
+		s.audit(r, AuditEvent{Type: AuditSessionTampered, Session: RedactSessionID(token), Reason: auditReasonCrossTenant})
+		return nil, httpError{message: unauthorizedMessage, code: http.StatusUnauthorized, cause: err}
+	}
+
+	if err := s.checkBinding(r, sessionData); err != nil {
+		return nil, httpError{message: unauthorizedMessage, code: http.StatusUnauthorized, cause: err}
+	}
+
+	s.touch(r, sessionData)
+	s.refreshRoles(r, sessionData)
 	return sessionData, nil
 }
 
+// touch records the session as seen from the client of r, at most once per
+// touch interval. Failing to record activity does not fail the request.
+func (s *Session) touch(r *http.Request, sessionData *SessionData) {
+	interval := s.TouchInterval
+	if interval == 0 {
+		interval = DefaultTouchInterval
+	}
+
+	now := time.Now()
+	if interval < 0 || now.Sub(sessionData.LastSeenAt) < interval {
+		return
+	}
+
+	activity := SessionActivity{SeenAt: now}
+	if addr, ok := ClientIP(r, s.TrustedProxies); ok {
+		activity.IP = addr.String()
+	}
+
+	if err := touchSession(s.store(r), sessionData.ID, activity); err != nil {
+		s.logger().Warn("recording session activity failed", sessionAttr(sessionData.ID), slog.Any("error", err))
+		return
+	}
+
+	sessionData.LastSeenAt = now
+	if activity.IP != "" {
+		sessionData.LastIP = activity.IP
+	}
+}
+
+// logger returns the logger of the session controller.
+func (s *Session) logger() *slog.Logger {
+	return loggerOrDiscard(s.Logger)
+}
+
+// store returns the session store to use while handling r. Stores that
+// implement TenantScoper are narrowed to the tenant of the request, store
+// calls are audited on behalf of the request and traced as children of the
+// span in its context.
+func (s *Session) store(r *http.Request) SessionStore {
+	return s.storeFor(r, "")
+}
+
+// storeFor works like store and gives reason as the cause of the sessions
+// created and revoked through it.
+func (s *Session) storeFor(r *http.Request, reason string) SessionStore {
+	store := s.Store
+	if tenant, ok := TenantFromContext(r.Context()); ok {
+		if scoper, ok := store.(TenantScoper); ok {
+			store = scoper.ForTenant(tenant)
+		}
+	}
+
+	if s.Audit != nil {
+		store = s.auditedStore(r, store, reason)
+	}
+	if s.Tracer != nil {
+		store = &tracedStore{store: store, tracer: s.Tracer, ctx: r.Context()}
+	}
+	return store
+}
+
 // handleHTTPError handles HTTP errors by sending the appropriate response.
 func handleHTTPError(w http.ResponseWriter, err error) {
 	var httpErr httpError
@@ -91,23 +490,23 @@ func handleHTTPError(w http.ResponseWriter, err error) {
 	}
 }
 
-// WithSession attaches a session to a context
-func WithSession(ctx context.Context, session *SessionData) context.Context {
-	return context.WithValue(ctx, CookieName, session)
-}
-
-// GetSessionFromContext retrieves a session from a context
-func GetSessionFromContext(ctx context.Context) (*SessionData, bool) {
-	session, ok := ctx.Value(CookieName).(*SessionData)
-	return session, ok
-}
+// sessionIDLength is the length of the IDs returned by generateSessionID.
+const sessionIDLength = 32
 
 // generateSessionID generates a random session ID
 func generateSessionID() string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, 32)
+	b := make([]byte, sessionIDLength)
 	for i := range b {
-		b[i] = charset[rand.Intn(len(charset))]
+		b[i] = sessionIDCharset[rand.Intn(len(sessionIDCharset))]
 	}
 	return string(b)
 }
+
+// sessionIDCharset holds the characters of session IDs.
+const sessionIDCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// sha256Hex returns the hex-encoded SHA-256 hash of s.
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -10,7 +10,10 @@ import (
 )
 
 type MockSessionStore struct {
-	GetSessionFunc func(sessionID string) (*SessionData, error)
+	CreateSessionFunc func(userID string, duration time.Duration) (*SessionData, error)
+	GetSessionFunc    func(sessionID string) (*SessionData, error)
+	UpdateSessionFunc func(session *SessionData) error
+	DeleteSessionFunc func(sessionID string) error
 }
 
 func (m *MockSessionStore) GetSession(sessionID string) (*SessionData, error) {
@@ -19,10 +22,39 @@ func (m *MockSessionStore) GetSession(sessionID string) (*SessionData, error) {
 
 // Implement remaining functions to satisfy interface
 func (m *MockSessionStore) CreateSession(userID string, duration time.Duration) (*SessionData, error) {
+	if m.CreateSessionFunc != nil {
+		return m.CreateSessionFunc(userID, duration)
+	}
 	return nil, nil
 }
-func (m *MockSessionStore) DeleteSession(sessionID string) error { return nil }
-func (m *MockSessionStore) CleanupExpiredSessions() error        { return nil }
+func (m *MockSessionStore) UpdateSession(session *SessionData) error {
+	if m.UpdateSessionFunc != nil {
+		return m.UpdateSessionFunc(session)
+	}
+	return nil
+}
+func (m *MockSessionStore) TouchSession(sessionID string, activity SessionActivity) error {
+	return nil
+}
+func (m *MockSessionStore) DeleteSession(sessionID string) error {
+	if m.DeleteSessionFunc != nil {
+		return m.DeleteSessionFunc(sessionID)
+	}
+	return nil
+}
+func (m *MockSessionStore) CleanupExpiredSessions() error { return nil }
+
+// basicStore hides the optional interfaces of a store, leaving only the
+// methods of SessionStore.
+type basicStore struct {
+	SessionStore
+}
+
+// updatingStore hides the optional interfaces of a store except SessionUpdater.
+type updatingStore struct {
+	SessionStore
+	SessionUpdater
+}
 
 func TestSession_ValidateSession(t *testing.T) {
 	mockSession := &Session{
@@ -157,6 +189,224 @@ func TestSession_ValidateAndFetchSession(t *testing.T) {
 	}
 }
 
+func TestSession_LoadSession(t *testing.T) {
+	mockSession := &Session{
+		Store: &MockSessionStore{
+			GetSessionFunc: func(sessionID string) (*SessionData, error) {
+				if sessionID == "valid-session" {
+					return &SessionData{
+						UserID:    "user1",
+						ExpiresAt: time.Now().Add(10 * time.Minute),
+					}, nil
+				}
+				return nil, errors.New("invalid session")
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		cookieValue   string
+		expectSession bool
+	}{
+		{
+			name:          "valid session",
+			cookieValue:   "valid-session",
+			expectSession: true,
+		},
+		{
+			name:          "missing cookie",
+			cookieValue:   "",
+			expectSession: false,
+		},
+		{
+			name:          "invalid session",
+			cookieValue:   "invalid-session",
+			expectSession: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookieValue != "" {
+				req.AddCookie(&http.Cookie{
+					Name:  CookieName,
+					Value: tt.cookieValue,
+				})
+			}
+
+			var found bool
+			rr := httptest.NewRecorder()
+			handler := mockSession.LoadSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, found = GetSessionFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			handler.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Errorf("expected code %d, got %d", http.StatusOK, rr.Code)
+			}
+			if found != tt.expectSession {
+				t.Errorf("expected session in context %v, got %v", tt.expectSession, found)
+			}
+		})
+	}
+}
+
+func TestSession_RequireSession(t *testing.T) {
+	mockSession := &Session{
+		Store: &MockSessionStore{
+			GetSessionFunc: func(sessionID string) (*SessionData, error) {
+				if sessionID == "valid-session" {
+					return &SessionData{
+						ExpiresAt: time.Now().Add(10 * time.Minute),
+					}, nil
+				}
+				return nil, errors.New("invalid session")
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		cookieValue string
+		expectCode  int
+	}{
+		{
+			name:        "valid session",
+			cookieValue: "valid-session",
+			expectCode:  http.StatusOK,
+		},
+		{
+			name:        "missing cookie",
+			cookieValue: "",
+			expectCode:  http.StatusUnauthorized,
+		},
+		{
+			name:        "invalid session",
+			cookieValue: "invalid-session",
+			expectCode:  http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookieValue != "" {
+				req.AddCookie(&http.Cookie{
+					Name:  CookieName,
+					Value: tt.cookieValue,
+				})
+			}
+
+			rr := httptest.NewRecorder()
+			handler := mockSession.LoadSession(mockSession.RequireSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})))
+
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.expectCode {
+				t.Errorf("expected code %d, got %d", tt.expectCode, rr.Code)
+			}
+		})
+	}
+}
+
+func TestSession_Metadata(t *testing.T) {
+	store := NewInMemorySessionStore()
+	s := &Session{Store: store, TouchInterval: time.Second}
+
+	login := httptest.NewRequest(http.MethodPost, "/login", nil)
+	login.RemoteAddr = "203.0.113.7:1234"
+	login.Header.Set("User-Agent", "Mozilla/5.0")
+
+	sessionData, err := s.Start(httptest.NewRecorder(), login, "user1", StartOptions{Device: "Work laptop"})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	stored, _ := store.GetSession(sessionData.ID)
+	if stored.CreatedIP != "203.0.113.7" || stored.LastIP != "203.0.113.7" {
+		t.Errorf("expected created and last IP 203.0.113.7, got %q and %q", stored.CreatedIP, stored.LastIP)
+	}
+	if stored.UserAgent != "Mozilla/5.0" {
+		t.Errorf("expected user agent Mozilla/5.0, got %q", stored.UserAgent)
+	}
+	if stored.Device != "Work laptop" {
+		t.Errorf("expected device Work laptop, got %q", stored.Device)
+	}
+
+	tests := []struct {
+		name         string
+		lastSeenAgo  time.Duration
+		remoteAddr   string
+		expectLastIP string
+	}{
+		{"throttled", 0, "198.51.100.1:1234", "203.0.113.7"},
+		{"interval elapsed", 2 * time.Second, "198.51.100.1:1234", "198.51.100.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = store.TouchSession(sessionData.ID, SessionActivity{SeenAt: time.Now().Add(-tt.lastSeenAgo)})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.AddCookie(&http.Cookie{Name: CookieName, Value: sessionData.ID})
+			if _, err := s.validateAndFetchSession(req); err != nil {
+				t.Fatalf("validateAndFetchSession() error = %v", err)
+			}
+
+			stored, _ := store.GetSession(sessionData.ID)
+			if stored.LastIP != tt.expectLastIP {
+				t.Errorf("expected last IP %q, got %q", tt.expectLastIP, stored.LastIP)
+			}
+		})
+	}
+}
+
+func TestSession_Touch(t *testing.T) {
+	tests := []struct {
+		name           string
+		interval       time.Duration
+		lastSeenAgo    time.Duration
+		expectTouched  bool
+		withoutToucher bool
+	}{
+		{"default interval elapsed", 0, 2 * DefaultTouchInterval, true, false},
+		{"default interval not elapsed", 0, DefaultTouchInterval / 2, false, false},
+		{"custom interval elapsed", time.Second, 2 * time.Second, true, false},
+		{"tracking disabled", -1, time.Hour, false, false},
+		{"store without TouchSession", 0, 2 * DefaultTouchInterval, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewInMemorySessionStore()
+			s := &Session{Store: store, TouchInterval: tt.interval}
+			if tt.withoutToucher {
+				s.Store = updatingStore{store, store}
+			}
+
+			sessionData, _ := store.CreateSession("user1", time.Hour)
+			lastSeen := time.Now().Add(-tt.lastSeenAgo)
+			_ = store.TouchSession(sessionData.ID, SessionActivity{SeenAt: lastSeen})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(&http.Cookie{Name: CookieName, Value: sessionData.ID})
+			if _, err := s.validateAndFetchSession(req); err != nil {
+				t.Fatalf("validateAndFetchSession() error = %v", err)
+			}
+
+			stored, _ := store.GetSession(sessionData.ID)
+			if touched := stored.LastSeenAt.After(lastSeen); touched != tt.expectTouched {
+				t.Errorf("expected touched %v, got %v", tt.expectTouched, touched)
+			}
+		})
+	}
+}
+
 func TestSetSessionCookie(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -197,6 +447,223 @@ func TestSetSessionCookie(t *testing.T) {
 	}
 }
 
+func TestClearSessionCookie(t *testing.T) {
+	rr := httptest.NewRecorder()
+
+	ClearSessionCookie(rr)
+
+	cookies := rr.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected 1 cookie, got %d", len(cookies))
+	}
+
+	cookie := cookies[0]
+	if cookie.Name != CookieName {
+		t.Errorf("expected cookie name %s, got %s", CookieName, cookie.Name)
+	}
+	if cookie.Value != "" {
+		t.Errorf("expected empty cookie value, got %s", cookie.Value)
+	}
+	if cookie.Path != "/" {
+		t.Errorf("expected cookie path /, got %s", cookie.Path)
+	}
+	if cookie.MaxAge >= 0 {
+		t.Errorf("expected negative MaxAge, got %d", cookie.MaxAge)
+	}
+}
+
+func TestSession_LogoutHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		cookieValue    string
+		redirectTo     string
+		deleteErr      error
+		expectCode     int
+		expectDeleted  string
+		expectLocation string
+	}{
+		{
+			name:          "logout with session",
+			cookieValue:   "valid-session",
+			expectCode:    http.StatusNoContent,
+			expectDeleted: "valid-session",
+		},
+		{
+			name:       "logout without session",
+			expectCode: http.StatusNoContent,
+		},
+		{
+			name:           "logout with redirect",
+			cookieValue:    "valid-session",
+			redirectTo:     "/login",
+			expectCode:     http.StatusSeeOther,
+			expectDeleted:  "valid-session",
+			expectLocation: "/login",
+		},
+		{
+			name:          "store failure",
+			cookieValue:   "valid-session",
+			deleteErr:     errors.New("store unavailable"),
+			expectCode:    http.StatusInternalServerError,
+			expectDeleted: "valid-session",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted string
+			s := &Session{
+				Store: &MockSessionStore{
+					DeleteSessionFunc: func(sessionID string) error {
+						deleted = sessionID
+						return tt.deleteErr
+					},
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+			if tt.cookieValue != "" {
+				req.AddCookie(&http.Cookie{
+					Name:  CookieName,
+					Value: tt.cookieValue,
+				})
+			}
+
+			rr := httptest.NewRecorder()
+			s.LogoutHandler(tt.redirectTo).ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectCode {
+				t.Errorf("expected code %d, got %d", tt.expectCode, rr.Code)
+			}
+			if deleted != tt.expectDeleted {
+				t.Errorf("expected deleted session %q, got %q", tt.expectDeleted, deleted)
+			}
+			if location := rr.Header().Get("Location"); location != tt.expectLocation {
+				t.Errorf("expected location %q, got %q", tt.expectLocation, location)
+			}
+
+			cookies := rr.Result().Cookies()
+			if len(cookies) != 1 || cookies[0].Name != CookieName || cookies[0].MaxAge >= 0 {
+				t.Errorf("expected an expired session cookie, got %v", cookies)
+			}
+		})
+	}
+}
+
+func TestSession_Start(t *testing.T) {
+	tests := []struct {
+		name           string
+		cookieValue    string
+		duration       time.Duration
+		expectDuration time.Duration
+	}{
+		{
+			name:           "default duration",
+			expectDuration: DefaultSessionDuration,
+		},
+		{
+			name:           "custom duration",
+			duration:       time.Hour,
+			expectDuration: time.Hour,
+		},
+		{
+			name:           "rotates existing session",
+			cookieValue:    "old-session",
+			expectDuration: DefaultSessionDuration,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := NewInMemorySessionStore()
+			s := &Session{Store: store}
+
+			req := httptest.NewRequest(http.MethodPost, "/login", nil)
+			if tt.cookieValue != "" {
+				old, _ := store.CreateSession("user1", time.Hour)
+				req.AddCookie(&http.Cookie{Name: CookieName, Value: old.ID})
+				defer func() {
+					if _, err := store.GetSession(old.ID); err == nil {
+						t.Error("expected previous session to be revoked")
+					}
+				}()
+			}
+
+			rr := httptest.NewRecorder()
+			sessionData, err := s.Start(rr, req, "user1", StartOptions{Duration: tt.duration})
+			if err != nil {
+				t.Fatalf("Start() error = %v", err)
+			}
+
+			if got := sessionData.ExpiresAt.Sub(sessionData.CreatedAt).Round(time.Second); got != tt.expectDuration {
+				t.Errorf("expected session duration %v, got %v", tt.expectDuration, got)
+			}
+
+			cookies := rr.Result().Cookies()
+			if len(cookies) != 1 {
+				t.Fatalf("expected 1 cookie, got %d", len(cookies))
+			}
+			if cookies[0].Value != sessionData.ID {
+				t.Errorf("expected cookie value %s, got %s", sessionData.ID, cookies[0].Value)
+			}
+			if !cookies[0].Expires.Equal(sessionData.ExpiresAt.Truncate(time.Second)) {
+				t.Errorf("expected cookie expiry %v, got %v", sessionData.ExpiresAt, cookies[0].Expires)
+			}
+		})
+	}
+}
+
+func TestSession_StartUpdateFailure(t *testing.T) {
+	failed := errors.New("storage unavailable")
+
+	tests := []struct {
+		name          string
+		basic         bool
+		updateErr     error
+		opts          StartOptions
+		expectErr     error
+		expectDeleted bool
+	}{
+		{"store without updates", true, nil, StartOptions{}, nil, false},
+		{"store without updates and roles", true, nil, StartOptions{Roles: []string{"admin"}}, ErrUpdateNotSupported, true},
+		{"failing update", false, failed, StartOptions{}, failed, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deleted []string
+			mock := &MockSessionStore{
+				CreateSessionFunc: func(userID string, duration time.Duration) (*SessionData, error) {
+					now := time.Now()
+					return &SessionData{ID: "created", UserID: userID, CreatedAt: now, ExpiresAt: now.Add(duration)}, nil
+				},
+				UpdateSessionFunc: func(session *SessionData) error { return tt.updateErr },
+				DeleteSessionFunc: func(sessionID string) error {
+					deleted = append(deleted, sessionID)
+					return nil
+				},
+			}
+			var store SessionStore = mock
+			if tt.basic {
+				store = basicStore{mock}
+			}
+			s := &Session{Store: store}
+
+			rr := httptest.NewRecorder()
+			_, err := s.Start(rr, httptest.NewRequest(http.MethodPost, "/login", nil), "user1", tt.opts)
+			if !errors.Is(err, tt.expectErr) {
+				t.Fatalf("Start() error = %v, want %v", err, tt.expectErr)
+			}
+			if tt.expectErr == nil && len(rr.Result().Cookies()) != 1 {
+				t.Error("expected a session cookie")
+			}
+			if got := len(deleted) == 1 && deleted[0] == "created"; got != tt.expectDeleted {
+				t.Errorf("created session deleted = %v (%v), want %v", got, deleted, tt.expectDeleted)
+			}
+		})
+	}
+}
+
 func TestGenerateSessionID(t *testing.T) {
 	tests := []struct {
 		name string
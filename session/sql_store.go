@@ -2,100 +2,826 @@ package session
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"log/slog"
 	"time"
 
 	_ "modernc.org/sqlite" // SQLite driver
 )
 
+// sessionColumns lists the columns read by scanSession, in order.
+const sessionColumns = "id, user_id, created_at, expires_at, data, ua_hash, ip_network, last_seen_at, " +
+	"created_ip, last_ip, user_agent, device, auth_level, authenticated_at, roles, scopes, roles_loaded_at, " +
+	"impersonator_id, impersonator_session_id, tenant"
+
+// migrations holds the schema changes of the sessions table in order. Each
+// entry is applied once and recorded in the schema_migrations table, so new
+// changes must only ever be appended.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS sessions (
+		id TEXT PRIMARY KEY,
+		user_id TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP NOT NULL
+	)`,
+	`ALTER TABLE sessions ADD COLUMN data TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE sessions ADD COLUMN ua_hash TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE sessions ADD COLUMN ip_network TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE sessions ADD COLUMN last_seen_at TIMESTAMP`,
+	`CREATE INDEX IF NOT EXISTS sessions_user_id ON sessions (user_id)`,
+	`CREATE TABLE IF NOT EXISTS remember_tokens (
+		selector TEXT PRIMARY KEY,
+		validator_hash TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		expires_at TIMESTAMP NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS remember_tokens_user_id ON remember_tokens (user_id)`,
+	`ALTER TABLE sessions ADD COLUMN created_ip TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE sessions ADD COLUMN last_ip TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE sessions ADD COLUMN device TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE sessions ADD COLUMN auth_level INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE sessions ADD COLUMN authenticated_at TIMESTAMP`,
+	`ALTER TABLE sessions ADD COLUMN roles TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE sessions ADD COLUMN scopes TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE sessions ADD COLUMN roles_loaded_at TIMESTAMP`,
+	`ALTER TABLE sessions ADD COLUMN impersonator_id TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE sessions ADD COLUMN impersonator_session_id TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE sessions ADD COLUMN tenant TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE remember_tokens ADD COLUMN tenant TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE remember_tokens ADD COLUMN previous_validator_hash TEXT NOT NULL DEFAULT ''`,
+	`ALTER TABLE remember_tokens ADD COLUMN rotated_at TIMESTAMP`,
+	`CREATE TABLE IF NOT EXISTS session_audit (
+		time TIMESTAMP NOT NULL,
+		type TEXT NOT NULL,
+		session TEXT NOT NULL,
+		previous TEXT NOT NULL DEFAULT '',
+		user_id TEXT NOT NULL DEFAULT '',
+		actor TEXT NOT NULL DEFAULT '',
+		tenant TEXT NOT NULL DEFAULT '',
+		ip TEXT NOT NULL DEFAULT '',
+		reason TEXT NOT NULL DEFAULT ''
+	)`,
+}
+
 // DBSessionStore is an SQL-based implementation of the SessionStore interface
 type DBSessionStore struct {
+	// Limit caps the number of active sessions per user.
+	Limit SessionLimit
+
+	// Logger receives expiries, cleanup runs and database errors. Nothing
+	// is logged while it is nil.
+	Logger *slog.Logger
+
 	db *sql.DB
 }
 
 func NewDBSessionStore(dsn string, driver string) (*DBSessionStore, error) {
+	store, err := OpenDBSessionStore(dsn, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.Migrate(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// OpenDBSessionStore opens the database without applying migrations, for
+// tools that must not change the schema unasked.
+func OpenDBSessionStore(dsn string, driver string) (*DBSessionStore, error) {
 	db, err := sql.Open(driver, dsn) // Pass driver (e.g., "sqlite3", "postgres", etc.)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS sessions (
-			id TEXT PRIMARY KEY,
-			user_id TEXT NOT NULL,
-			created_at TIMESTAMP NOT NULL,
-			expires_at TIMESTAMP NOT NULL
+	return &DBSessionStore{db: db}, nil
+}
+
+// Close closes the database.
+func (s *DBSessionStore) Close() error {
+	return s.db.Close()
+}
+
+// Migrate applies all schema migrations that have not been applied yet.
+func (s *DBSessionStore) Migrate() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			applied_at TIMESTAMP NOT NULL
 		)
 	`)
+	if err != nil {
+		return err
+	}
+
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	for i := version; i < len(migrations); i++ {
+		if err := s.applyMigration(i+1, migrations[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SchemaVersion returns the number of migrations applied to the database.
+func (s *DBSessionStore) SchemaVersion() (int, error) {
+	var version sql.NullInt64
+	err := s.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// PendingMigrations returns the number of migrations Migrate would apply.
+func (s *DBSessionStore) PendingMigrations() (int, error) {
+	if err := s.db.Ping(); err != nil {
+		return 0, err
+	}
+
+	version, err := s.SchemaVersion()
+	if err != nil {
+		// The database is reachable, so schema_migrations does not exist yet.
+		return len(migrations), nil
+	}
+	return len(migrations) - version, nil
+}
+
+// applyMigration runs a single migration and records it in one transaction.
+func (s *DBSessionStore) applyMigration(version int, statement string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(statement); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO schema_migrations (version, applied_at)
+		VALUES (?, ?)
+	`, version, time.Now())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanSession reads a session selected with sessionColumns.
+func scanSession(row rowScanner) (*SessionData, error) {
+	var session SessionData
+	var data, roles, scopes string
+	var lastSeenAt, authenticatedAt, rolesLoadedAt sql.NullTime
+	err := row.Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.ExpiresAt, &data,
+		&session.UserAgentHash, &session.IPNetwork, &lastSeenAt,
+		&session.CreatedIP, &session.LastIP, &session.UserAgent, &session.Device,
+		&session.AuthLevel, &authenticatedAt, &roles, &scopes, &rolesLoadedAt,
+		&session.ImpersonatorID, &session.ImpersonatorSessionID, &session.Tenant)
 	if err != nil {
 		return nil, err
 	}
+	session.LastSeenAt = lastSeenAt.Time
+	session.AuthenticatedAt = authenticatedAt.Time
+	session.RolesLoadedAt = rolesLoadedAt.Time
 
-	return &DBSessionStore{db: db}, nil
+	if err := decodeList(roles, &session.Roles); err != nil {
+		return nil, err
+	}
+	if err := decodeList(scopes, &session.Scopes); err != nil {
+		return nil, err
+	}
+
+	if data != "" {
+		if err := json.Unmarshal([]byte(data), &session.Values); err != nil {
+			return nil, err
+		}
+	}
+
+	return &session, nil
+}
+
+// encodeValues serializes session values for the data column.
+func encodeValues(values map[string]string) (string, error) {
+	if len(values) == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// encodeList serializes a string list for a text column.
+func encodeList(list []string) (string, error) {
+	if len(list) == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decodeList reads a string list written by encodeList.
+func decodeList(data string, list *[]string) error {
+	if data == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), list)
 }
 
 // CreateSession creates a new session and stores it in the database
 func (s *DBSessionStore) CreateSession(userID string, duration time.Duration) (*SessionData, error) {
+	session, _, err := s.createSession(allTenants, userID, duration)
+	return session, err
+}
+
+// CreateSessionEvicting creates a session like CreateSession and returns the
+// sessions revoked by the session limit.
+func (s *DBSessionStore) CreateSessionEvicting(userID string, duration time.Duration) (*SessionData, []*SessionData, error) {
+	return s.createSession(allTenants, userID, duration)
+}
+
+func (s *DBSessionStore) createSession(scope tenantScope, userID string, duration time.Duration) (_ *SessionData, _ []*SessionData, err error) {
+	defer func() { logBackendError(s.Logger, "create", err) }()
+
 	if userID == "" {
-		return nil, errors.New("user ID is required")
+		return nil, nil, errors.New("user ID is required")
 	}
 
+	now := time.Now()
 	session := &SessionData{
-		ID:        generateSessionID(),
-		UserID:    userID,
-		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(duration),
+		ID:         generateSessionID(),
+		UserID:     userID,
+		Tenant:     scope.tenant,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(duration),
+		LastSeenAt: now,
 	}
 
-	_, err := s.db.Exec(`
-		INSERT INTO sessions (id, user_id, created_at, expires_at)
-		VALUES (?, ?, ?, ?)
-	`, session.ID, session.UserID, session.CreatedAt, session.ExpiresAt)
+	// The limit check and the insert share a transaction, so concurrent
+	// logins cannot exceed the limit.
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	evicted, err := s.enforceLimit(tx, session.Tenant, userID, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO sessions (id, user_id, tenant, created_at, expires_at, last_seen_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, session.ID, session.UserID, session.Tenant, session.CreatedAt, session.ExpiresAt, session.LastSeenAt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	return session, evicted, nil
+}
+
+// enforceLimit revokes sessions of a user as required by the session limit
+// and returns them.
+func (s *DBSessionStore) enforceLimit(tx *sql.Tx, tenant, userID string, now time.Time) ([]*SessionData, error) {
+	if s.Limit.MaxSessions <= 0 {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT `+sessionColumns+`
+		FROM sessions
+		WHERE user_id = ? AND tenant = ? AND expires_at >= ?
+	`, userID, tenant, now)
 	if err != nil {
 		return nil, err
 	}
 
-	return session, nil
+	var active []*SessionData
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		active = append(active, session)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	evict, err := s.Limit.evictions(active)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range evict {
+		if _, err := tx.Exec(`DELETE FROM sessions WHERE id = ?`, session.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return evict, nil
 }
 
 // GetSession retrieves a session by its ID
 func (s *DBSessionStore) GetSession(sessionID string) (*SessionData, error) {
+	return s.getSession(allTenants, sessionID)
+}
+
+func (s *DBSessionStore) getSession(scope tenantScope, sessionID string) (*SessionData, error) {
+	session, err := s.lookupSession(scope, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.ExpiresAt.Before(time.Now()) {
+		loggerOrDiscard(s.Logger).Debug("session expired", sessionAttr(sessionID))
+		_ = s.deleteSession(scope, sessionID)
+		return nil, ErrSessionExpired
+	}
+
+	return session, nil
+}
+
+// LookupSession returns a session whether or not it has expired. Unlike
+// GetSession it never deletes it, so it suits tools inspecting the database.
+func (s *DBSessionStore) LookupSession(sessionID string) (*SessionData, error) {
+	return s.lookupSession(allTenants, sessionID)
+}
+
+func (s *DBSessionStore) lookupSession(scope tenantScope, sessionID string) (_ *SessionData, err error) {
+	defer func() { logBackendError(s.Logger, "get", err) }()
+
+	condition, args := scope.where()
 	row := s.db.QueryRow(`
-		SELECT id, user_id, created_at, expires_at
+		SELECT `+sessionColumns+`
 		FROM sessions
-		WHERE id = ?
-	`, sessionID)
+		WHERE id = ?`+condition,
+		append([]any{sessionID}, args...)...)
 
-	var session SessionData
-	err := row.Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.ExpiresAt)
+	session, err := scanSession(row)
 	if err == sql.ErrNoRows {
-		return nil, errors.New("session not found")
-	} else if err != nil {
-		return nil, err
+		return nil, ErrSessionNotFound
 	}
+	return session, err
+}
 
-	if session.ExpiresAt.Before(time.Now()) {
-		_ = s.DeleteSession(sessionID)
-		return nil, errors.New("session expired")
+// UpdateSession stores the mutable fields of an existing session
+func (s *DBSessionStore) UpdateSession(session *SessionData) error {
+	return s.updateSession(allTenants, session)
+}
+
+func (s *DBSessionStore) updateSession(scope tenantScope, session *SessionData) (err error) {
+	defer func() { logBackendError(s.Logger, "update", err) }()
+
+	data, err := encodeValues(session.Values)
+	if err != nil {
+		return err
+	}
+	roles, err := encodeList(session.Roles)
+	if err != nil {
+		return err
+	}
+	scopes, err := encodeList(session.Scopes)
+	if err != nil {
+		return err
 	}
 
-	return &session, nil
+	condition, args := scope.where()
+	result, err := s.db.Exec(`
+		UPDATE sessions
+		SET expires_at = ?, data = ?, ua_hash = ?, ip_network = ?, last_seen_at = ?,
+			created_ip = ?, last_ip = ?, user_agent = ?, device = ?,
+			auth_level = ?, authenticated_at = ?, roles = ?, scopes = ?, roles_loaded_at = ?,
+			impersonator_id = ?, impersonator_session_id = ?
+		WHERE id = ?`+condition,
+		append([]any{session.ExpiresAt, data, session.UserAgentHash, session.IPNetwork, session.LastSeenAt,
+			session.CreatedIP, session.LastIP, session.UserAgent, session.Device,
+			session.AuthLevel, session.AuthenticatedAt, roles, scopes, session.RolesLoadedAt,
+			session.ImpersonatorID, session.ImpersonatorSessionID, session.ID}, args...)...)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// TouchSession records activity on a session
+func (s *DBSessionStore) TouchSession(sessionID string, activity SessionActivity) error {
+	return s.touchSession(allTenants, sessionID, activity)
+}
+
+func (s *DBSessionStore) touchSession(scope tenantScope, sessionID string, activity SessionActivity) (err error) {
+	defer func() { logBackendError(s.Logger, "touch", err) }()
+
+	condition, args := scope.where()
+	_, err = s.db.Exec(`
+		UPDATE sessions
+		SET last_seen_at = ?, last_ip = COALESCE(NULLIF(?, ''), last_ip)
+		WHERE id = ?`+condition,
+		append([]any{activity.SeenAt, activity.IP, sessionID}, args...)...)
+	return err
 }
 
 // DeleteSession deletes a session by its ID
 func (s *DBSessionStore) DeleteSession(sessionID string) error {
-	_, err := s.db.Exec(`
+	return s.deleteSession(allTenants, sessionID)
+}
+
+func (s *DBSessionStore) deleteSession(scope tenantScope, sessionID string) (err error) {
+	defer func() { logBackendError(s.Logger, "delete", err) }()
+
+	condition, args := scope.where()
+	_, err = s.db.Exec(`
 		DELETE FROM sessions
-		WHERE id = ?
-	`, sessionID)
+		WHERE id = ?`+condition,
+		append([]any{sessionID}, args...)...)
 	return err
 }
 
-// CleanupExpiredSessions removes all expired sessions from the database
+// CleanupExpiredSessions removes all expired sessions and remember-me tokens from the database
 func (s *DBSessionStore) CleanupExpiredSessions() error {
-	_, err := s.db.Exec(`
+	_, err := s.purgeExpiredSessions(allTenants)
+	return err
+}
+
+// PurgeExpiredSessions removes expired sessions and remember-me tokens and
+// returns the removed sessions
+func (s *DBSessionStore) PurgeExpiredSessions() ([]*SessionData, error) {
+	return s.purgeExpiredSessions(allTenants)
+}
+
+func (s *DBSessionStore) purgeExpiredSessions(scope tenantScope) (_ []*SessionData, err error) {
+	defer func() { logBackendError(s.Logger, "cleanup", err) }()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	condition, args := scope.where()
+	args = append([]any{now}, args...)
+
+	rows, err := tx.Query(`
+		SELECT `+sessionColumns+`
+		FROM sessions
+		WHERE expires_at < ?`+condition,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var purged []*SessionData
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		purged = append(purged, session)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
 		DELETE FROM sessions
-		WHERE expires_at < ?
-	`, time.Now())
+		WHERE expires_at < ?`+condition,
+		args...); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM remember_tokens
+		WHERE expires_at < ?`+condition,
+		args...); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	loggerOrDiscard(s.Logger).Debug("expired sessions cleaned up", slog.Int("deleted", len(purged)))
+	return purged, nil
+}
+
+// CountActiveSessions returns the number of unexpired sessions
+func (s *DBSessionStore) CountActiveSessions() (int, error) {
+	return s.countActiveSessions(allTenants)
+}
+
+func (s *DBSessionStore) countActiveSessions(scope tenantScope) (_ int, err error) {
+	defer func() { logBackendError(s.Logger, "count", err) }()
+
+	condition, args := scope.where()
+
+	var count int
+	err = s.db.QueryRow(`
+		SELECT COUNT(*)
+		FROM sessions
+		WHERE expires_at >= ?`+condition,
+		append([]any{time.Now()}, args...)...).Scan(&count)
+	return count, err
+}
+
+// ListSessions returns the sessions matching query, ordered by ID
+func (s *DBSessionStore) ListSessions(query SessionQuery) ([]*SessionData, error) {
+	return s.listSessions(allTenants, query)
+}
+
+func (s *DBSessionStore) listSessions(scope tenantScope, query SessionQuery) (_ []*SessionData, err error) {
+	defer func() { logBackendError(s.Logger, "list", err) }()
+
+	condition, args := scope.where()
+	args = append([]any{query.After}, args...)
+	if query.UserID != "" {
+		condition += " AND user_id = ?"
+		args = append(args, query.UserID)
+	}
+	if !query.IncludeExpired {
+		condition += " AND expires_at >= ?"
+		args = append(args, time.Now())
+	}
+	limit := ""
+	if query.Limit > 0 {
+		limit = " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+sessionColumns+`
+		FROM sessions
+		WHERE id > ?`+condition+`
+		ORDER BY id`+limit,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*SessionData
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// ImportSession stores session with its ID, replacing any session with the same ID
+func (s *DBSessionStore) ImportSession(session *SessionData) error {
+	return s.importSession(allTenants, session)
+}
+
+func (s *DBSessionStore) importSession(scope tenantScope, session *SessionData) (err error) {
+	defer func() { logBackendError(s.Logger, "import", err) }()
+
+	tenant := session.Tenant
+	if scope.scoped {
+		tenant = scope.tenant
+	}
+
+	data, err := encodeValues(session.Values)
+	if err != nil {
+		return err
+	}
+	roles, err := encodeList(session.Roles)
+	if err != nil {
+		return err
+	}
+	scopes, err := encodeList(session.Scopes)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if scope.scoped {
+		var existing string
+		err := tx.QueryRow(`SELECT tenant FROM sessions WHERE id = ?`, session.ID).Scan(&existing)
+		if err == nil && existing != scope.tenant {
+			return errTenantMismatch
+		} else if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE id = ?`, session.ID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO sessions (`+sessionColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, session.ID, session.UserID, session.CreatedAt, session.ExpiresAt, data,
+		session.UserAgentHash, session.IPNetwork, session.LastSeenAt,
+		session.CreatedIP, session.LastIP, session.UserAgent, session.Device,
+		session.AuthLevel, session.AuthenticatedAt, roles, scopes, session.RolesLoadedAt,
+		session.ImpersonatorID, session.ImpersonatorSessionID, tenant)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AuditSink returns a sink writing audit events to the session_audit table
+// of the store's database
+func (s *DBSessionStore) AuditSink() (*SQLAuditSink, error) {
+	return NewSQLAuditSink(s.db)
+}
+
+// ForTenant returns a view of the store restricted to the sessions of one
+// tenant. Sessions created through it belong to the tenant, and sessions of
+// other tenants cannot be read, changed or deleted through it.
+func (s *DBSessionStore) ForTenant(tenant string) SessionStore {
+	return &dbTenantStore{store: s, scope: tenantScope{tenant: tenant, scoped: true}}
+}
+
+// dbTenantStore is the tenant-scoped view returned by DBSessionStore.ForTenant.
+type dbTenantStore struct {
+	store *DBSessionStore
+	scope tenantScope
+}
+
+func (t *dbTenantStore) CreateSession(userID string, duration time.Duration) (*SessionData, error) {
+	session, _, err := t.store.createSession(t.scope, userID, duration)
+	return session, err
+}
+
+func (t *dbTenantStore) CreateSessionEvicting(userID string, duration time.Duration) (*SessionData, []*SessionData, error) {
+	return t.store.createSession(t.scope, userID, duration)
+}
+
+func (t *dbTenantStore) GetSession(sessionID string) (*SessionData, error) {
+	return t.store.getSession(t.scope, sessionID)
+}
+
+func (t *dbTenantStore) LookupSession(sessionID string) (*SessionData, error) {
+	return t.store.lookupSession(t.scope, sessionID)
+}
+
+func (t *dbTenantStore) UpdateSession(session *SessionData) error {
+	return t.store.updateSession(t.scope, session)
+}
+
+func (t *dbTenantStore) TouchSession(sessionID string, activity SessionActivity) error {
+	return t.store.touchSession(t.scope, sessionID, activity)
+}
+
+func (t *dbTenantStore) DeleteSession(sessionID string) error {
+	return t.store.deleteSession(t.scope, sessionID)
+}
+
+func (t *dbTenantStore) CleanupExpiredSessions() error {
+	_, err := t.store.purgeExpiredSessions(t.scope)
+	return err
+}
+
+func (t *dbTenantStore) PurgeExpiredSessions() ([]*SessionData, error) {
+	return t.store.purgeExpiredSessions(t.scope)
+}
+
+func (t *dbTenantStore) CountActiveSessions() (int, error) {
+	return t.store.countActiveSessions(t.scope)
+}
+
+func (t *dbTenantStore) ListSessions(query SessionQuery) ([]*SessionData, error) {
+	return t.store.listSessions(t.scope, query)
+}
+
+// ImportSession imports session into the tenant, whatever its Tenant field
+// says. It fails if a session of another tenant has the same ID.
+func (t *dbTenantStore) ImportSession(session *SessionData) error {
+	return t.store.importSession(t.scope, session)
+}
+
+// SaveRememberToken inserts a remember-me token or replaces the one with the same selector
+func (s *DBSessionStore) SaveRememberToken(token *RememberToken) (err error) {
+	defer func() { logBackendError(s.Logger, "save remember token", err) }()
+
+	_, err = s.db.Exec(`
+		INSERT INTO remember_tokens (selector, validator_hash, user_id, tenant, expires_at,
+			previous_validator_hash, rotated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (selector) DO UPDATE
+		SET validator_hash = excluded.validator_hash, user_id = excluded.user_id,
+			tenant = excluded.tenant, expires_at = excluded.expires_at,
+			previous_validator_hash = excluded.previous_validator_hash, rotated_at = excluded.rotated_at
+	`, token.Selector, token.ValidatorHash, token.UserID, token.Tenant, token.ExpiresAt,
+		token.PreviousValidatorHash, token.RotatedAt)
+	return err
+}
+
+// RotateRememberToken replaces the validator of a remember-me token if it was
+// not rotated in the meantime
+func (s *DBSessionStore) RotateRememberToken(token *RememberToken) (saved bool, err error) {
+	defer func() { logBackendError(s.Logger, "rotate remember token", err) }()
+
+	result, err := s.db.Exec(`
+		UPDATE remember_tokens
+		SET validator_hash = ?, previous_validator_hash = ?, rotated_at = ?
+		WHERE selector = ? AND validator_hash = ?
+	`, token.ValidatorHash, token.PreviousValidatorHash, token.RotatedAt,
+		token.Selector, token.PreviousValidatorHash)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// GetRememberToken retrieves a remember-me token by its selector
+func (s *DBSessionStore) GetRememberToken(selector string) (*RememberToken, error) {
+	row := s.db.QueryRow(`
+		SELECT selector, validator_hash, user_id, tenant, expires_at, previous_validator_hash, rotated_at
+		FROM remember_tokens
+		WHERE selector = ?
+	`, selector)
+
+	var token RememberToken
+	var rotatedAt sql.NullTime
+	err := row.Scan(&token.Selector, &token.ValidatorHash, &token.UserID, &token.Tenant, &token.ExpiresAt,
+		&token.PreviousValidatorHash, &rotatedAt)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("remember-me token not found")
+	} else if err != nil {
+		return nil, err
+	}
+	token.RotatedAt = rotatedAt.Time
+
+	return &token, nil
+}
+
+// DeleteRememberToken deletes a remember-me token by its selector
+func (s *DBSessionStore) DeleteRememberToken(selector string) (err error) {
+	defer func() { logBackendError(s.Logger, "delete remember token", err) }()
+
+	_, err = s.db.Exec(`
+		DELETE FROM remember_tokens
+		WHERE selector = ?
+	`, selector)
+	return err
+}
+
+// DeleteUserRememberTokens deletes all remember-me tokens of a user within a tenant
+func (s *DBSessionStore) DeleteUserRememberTokens(tenant, userID string) (err error) {
+	defer func() { logBackendError(s.Logger, "delete user remember tokens", err) }()
+
+	_, err = s.db.Exec(`
+		DELETE FROM remember_tokens
+		WHERE user_id = ? AND tenant = ?
+	`, userID, tenant)
 	return err
 }
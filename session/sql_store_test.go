@@ -1,6 +1,9 @@
 package session
 
 import (
+	"database/sql"
+	"errors"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -74,6 +77,44 @@ func TestDBSessionStore_GetSession(t *testing.T) {
 	}
 }
 
+func TestDBSessionStore_LookupSession(t *testing.T) {
+	store := setupTestDB(t)
+
+	expiredSession, _ := store.CreateSession("user3", -1*time.Second)
+	acme, _ := store.ForTenant("acme").CreateSession("user4", time.Hour)
+
+	tests := []struct {
+		name  string
+		store interface {
+			LookupSession(string) (*SessionData, error)
+		}
+		sessionID string
+		wantErr   error
+	}{
+		{"expired session", store, expiredSession.ID, nil},
+		{"nonexistent session", store, "nonexistent", ErrSessionNotFound},
+		{"session of the tenant", store.ForTenant("acme").(*dbTenantStore), acme.ID, nil},
+		{"session of another tenant", store.ForTenant("globex").(*dbTenantStore), acme.ID, ErrSessionNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, err := tt.store.LookupSession(tt.sessionID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("LookupSession() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && session.ID != tt.sessionID {
+				t.Errorf("LookupSession() = %+v", session)
+			}
+		})
+	}
+
+	// Looking an expired session up leaves it in the database.
+	if _, err := store.LookupSession(expiredSession.ID); err != nil {
+		t.Errorf("expected the expired session to be kept, got %v", err)
+	}
+}
+
 func TestDBSessionStore_DeleteSession(t *testing.T) {
 	store := setupTestDB(t)
 
@@ -134,3 +175,219 @@ func TestDBSessionStore_CleanupExpiredSessions(t *testing.T) {
 		})
 	}
 }
+
+func TestDBSessionStore_UpdateSession(t *testing.T) {
+	store := setupTestDB(t)
+
+	session, _ := store.CreateSession("user8", 1*time.Hour)
+
+	tests := []struct {
+		name    string
+		session *SessionData
+		values  map[string]string
+		wantErr bool
+	}{
+		{"set values", session, map[string]string{"key": "value"}, false},
+		{"clear values", session, nil, false},
+		{"nonexistent session", &SessionData{ID: "nonexistent"}, map[string]string{"key": "value"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.session.Values = tt.values
+			err := store.UpdateSession(tt.session)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdateSession() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			stored, err := store.GetSession(tt.session.ID)
+			if err != nil {
+				t.Fatalf("GetSession() error = %v", err)
+			}
+			if len(stored.Values) != len(tt.values) || stored.Values["key"] != tt.values["key"] {
+				t.Errorf("UpdateSession() stored values = %v, want %v", stored.Values, tt.values)
+			}
+		})
+	}
+}
+
+func TestDBSessionStore_Migrate(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "legacy.db")
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatalf("failed to open legacy DB: %v", err)
+	}
+	_, err = db.Exec(`
+		CREATE TABLE sessions (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP NOT NULL
+		)
+	`)
+	if err != nil {
+		t.Fatalf("failed to create legacy schema: %v", err)
+	}
+	_, err = db.Exec(`INSERT INTO sessions (id, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)`,
+		"legacy-session", "user9", time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("failed to insert legacy session: %v", err)
+	}
+	_ = db.Close()
+
+	store, err := NewDBSessionStore(dsn, "sqlite")
+	if err != nil {
+		t.Fatalf("NewDBSessionStore() error = %v", err)
+	}
+
+	version, err := store.SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion() error = %v", err)
+	}
+	if version != len(migrations) {
+		t.Errorf("SchemaVersion() = %d, want %d", version, len(migrations))
+	}
+
+	if _, err := store.GetSession("legacy-session"); err != nil {
+		t.Errorf("GetSession() on migrated DB error = %v", err)
+	}
+
+	if err := store.Migrate(); err != nil {
+		t.Errorf("Migrate() on up-to-date DB error = %v", err)
+	}
+}
+
+func TestDBSessionStore_PendingMigrations(t *testing.T) {
+	store, err := OpenDBSessionStore(filepath.Join(t.TempDir(), "sessions.db"), "sqlite")
+	if err != nil {
+		t.Fatalf("OpenDBSessionStore() error = %v", err)
+	}
+	defer store.Close()
+
+	if pending, err := store.PendingMigrations(); err != nil || pending != len(migrations) {
+		t.Errorf("PendingMigrations() = %d, %v; want %d", pending, err, len(migrations))
+	}
+
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if pending, err := store.PendingMigrations(); err != nil || pending != 0 {
+		t.Errorf("PendingMigrations() after Migrate = %d, %v; want 0", pending, err)
+	}
+}
+
+func TestDBSessionStore_ClientBinding(t *testing.T) {
+	store := setupTestDB(t)
+
+	session, _ := store.CreateSession("user10", 1*time.Hour)
+	session.UserAgentHash = sha256Hex("Mozilla/5.0")
+	session.IPNetwork = "203.0.113.0/24"
+	if err := store.UpdateSession(session); err != nil {
+		t.Fatalf("UpdateSession() error = %v", err)
+	}
+
+	stored, err := store.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("GetSession() error = %v", err)
+	}
+	if stored.UserAgentHash != session.UserAgentHash || stored.IPNetwork != session.IPNetwork {
+		t.Errorf("GetSession() binding = %q, %q, want %q, %q",
+			stored.UserAgentHash, stored.IPNetwork, session.UserAgentHash, session.IPNetwork)
+	}
+}
+
+func TestDBSessionStore_Metadata(t *testing.T) {
+	store := setupTestDB(t)
+
+	session, _ := store.CreateSession("user11", 1*time.Hour)
+	session.CreatedIP = "203.0.113.7"
+	session.LastIP = "203.0.113.7"
+	session.UserAgent = "Mozilla/5.0"
+	session.Device = "Work laptop"
+	if err := store.UpdateSession(session); err != nil {
+		t.Fatalf("UpdateSession() error = %v", err)
+	}
+
+	seenAt := time.Now().Add(time.Minute)
+	tests := []struct {
+		name         string
+		activity     SessionActivity
+		expectLastIP string
+	}{
+		{"touch without IP", SessionActivity{SeenAt: seenAt}, "203.0.113.7"},
+		{"touch with IP", SessionActivity{SeenAt: seenAt, IP: "198.51.100.1"}, "198.51.100.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := store.TouchSession(session.ID, tt.activity); err != nil {
+				t.Fatalf("TouchSession() error = %v", err)
+			}
+
+			stored, err := store.GetSession(session.ID)
+			if err != nil {
+				t.Fatalf("GetSession() error = %v", err)
+			}
+			if stored.LastIP != tt.expectLastIP {
+				t.Errorf("LastIP = %q, want %q", stored.LastIP, tt.expectLastIP)
+			}
+			if !stored.LastSeenAt.Equal(seenAt) {
+				t.Errorf("LastSeenAt = %v, want %v", stored.LastSeenAt, seenAt)
+			}
+			if stored.CreatedIP != session.CreatedIP || stored.UserAgent != session.UserAgent || stored.Device != session.Device {
+				t.Errorf("GetSession() metadata = %q, %q, %q, want %q, %q, %q",
+					stored.CreatedIP, stored.UserAgent, stored.Device, session.CreatedIP, session.UserAgent, session.Device)
+			}
+		})
+	}
+}
+
+func TestDBSessionStore_AuthLevel(t *testing.T) {
+	store := setupTestDB(t)
+
+	session, _ := store.CreateSession("user12", 1*time.Hour)
+	session.AuthLevel = AuthLevelMFA
+	session.AuthenticatedAt = time.Now()
+	if err := store.UpdateSession(session); err != nil {
+		t.Fatalf("UpdateSession() error = %v", err)
+	}
+
+	stored, err := store.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("GetSession() error = %v", err)
+	}
+	if stored.AuthLevel != session.AuthLevel || !stored.AuthenticatedAt.Equal(session.AuthenticatedAt) {
+		t.Errorf("GetSession() auth = %v at %v, want %v at %v",
+			stored.AuthLevel, stored.AuthenticatedAt, session.AuthLevel, session.AuthenticatedAt)
+	}
+}
+
+func TestDBSessionStore_Roles(t *testing.T) {
+	store := setupTestDB(t)
+
+	session, _ := store.CreateSession("user13", 1*time.Hour)
+	session.Roles = []string{"admin", "support"}
+	session.Scopes = []string{"orders:read"}
+	session.RolesLoadedAt = time.Now()
+	if err := store.UpdateSession(session); err != nil {
+		t.Fatalf("UpdateSession() error = %v", err)
+	}
+
+	stored, err := store.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("GetSession() error = %v", err)
+	}
+	if len(stored.Roles) != 2 || stored.Roles[0] != "admin" || stored.Roles[1] != "support" {
+		t.Errorf("GetSession() roles = %v, want %v", stored.Roles, session.Roles)
+	}
+	if len(stored.Scopes) != 1 || stored.Scopes[0] != "orders:read" {
+		t.Errorf("GetSession() scopes = %v, want %v", stored.Scopes, session.Scopes)
+	}
+	if !stored.RolesLoadedAt.Equal(session.RolesLoadedAt) {
+		t.Errorf("GetSession() roles loaded at = %v, want %v", stored.RolesLoadedAt, session.RolesLoadedAt)
+	}
+}
@@ -1,15 +1,102 @@
 package session
 
 import (
+	"errors"
 	"time"
 )
 
+var (
+	// ErrSessionNotFound is returned by stores for unknown session IDs.
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrSessionExpired is returned by stores for sessions past their expiry.
+	ErrSessionExpired = errors.New("session expired")
+)
+
 // SessionData represents the structure of a session
 type SessionData struct {
-	ID        string
-	UserID    string
+	ID     string
+	UserID string
+	// Tenant namespaces the session in multi-tenant deployments. It is set
+	// when the session is created and never changes.
+	Tenant    string
 	CreatedAt time.Time
 	ExpiresAt time.Time
+
+	// LastSeenAt is the last time the session was used, updated at most once
+	// per Session.TouchInterval.
+	LastSeenAt time.Time
+
+	// CreatedIP and UserAgent describe the client that created the session,
+	// LastIP the client that used it last. Device is an optional label
+	// chosen at login, such as "Work laptop".
+	CreatedIP string
+	LastIP    string
+	UserAgent string
+	Device    string
+
+	// AuthLevel is the strongest authentication the user completed in this
+	// session, reached at AuthenticatedAt.
+	AuthLevel       AuthLevel
+	AuthenticatedAt time.Time
+
+	// Roles and Scopes carry the user's permissions, loaded at RolesLoadedAt.
+	Roles         []string
+	Scopes        []string
+	RolesLoadedAt time.Time
+
+	// ImpersonatorID is the user ID of the admin acting as UserID, and
+	// ImpersonatorSessionID the admin session to return to. Both are empty
+	// for regular sessions.
+	ImpersonatorID        string
+	ImpersonatorSessionID string
+
+	// UserAgentHash and IPNetwork bind the session to the client that
+	// created it. They are empty when client binding is disabled.
+	UserAgentHash string
+	IPNetwork     string
+
+	// Values holds additional data attached to the session, such as the CSRF
+	// secret. It is persisted by SessionUpdater.UpdateSession.
+	Values map[string]string
+}
+
+// clone returns a copy of the session that shares no mutable state with it.
+func (d *SessionData) clone() *SessionData {
+	c := *d
+	c.Roles = append([]string(nil), d.Roles...)
+	c.Scopes = append([]string(nil), d.Scopes...)
+	if d.Values != nil {
+		c.Values = make(map[string]string, len(d.Values))
+		for k, v := range d.Values {
+			c.Values[k] = v
+		}
+	}
+	return &c
+}
+
+// authenticatedAt returns AuthenticatedAt, falling back to CreatedAt for
+// sessions stored without it.
+func (d *SessionData) authenticatedAt() time.Time {
+	if d.AuthenticatedAt.IsZero() {
+		return d.CreatedAt
+	}
+	return d.AuthenticatedAt
+}
+
+// lastSeen returns LastSeenAt, falling back to CreatedAt for sessions that
+// were never touched.
+func (d *SessionData) lastSeen() time.Time {
+	if d.LastSeenAt.IsZero() {
+		return d.CreatedAt
+	}
+	return d.LastSeenAt
+}
+
+// SessionActivity describes a request made with a session.
+type SessionActivity struct {
+	SeenAt time.Time
+	// IP is the client address of the request. Empty leaves LastIP unchanged.
+	IP string
 }
 
 // SessionStore defines an interface for session storage backends
@@ -19,3 +106,130 @@ type SessionStore interface {
 	DeleteSession(sessionID string) error
 	CleanupExpiredSessions() error
 }
+
+// ErrUpdateNotSupported is returned when changes to a session need to be
+// saved to a store that does not implement SessionUpdater.
+var ErrUpdateNotSupported = errors.New("store cannot update sessions")
+
+// SessionUpdater is implemented by stores that can persist changes made to
+// an existing session. Session.Start, CSRF protection, flash messages,
+// Elevate, role loading and impersonation need it.
+type SessionUpdater interface {
+	UpdateSession(session *SessionData) error
+}
+
+// updateSession updates sessions in stores implementing SessionUpdater.
+func updateSession(store SessionStore, session *SessionData) error {
+	if updater, ok := store.(SessionUpdater); ok {
+		return updater.UpdateSession(session)
+	}
+	return ErrUpdateNotSupported
+}
+
+// SessionToucher is implemented by stores that can record activity on a
+// session without rewriting its other fields.
+type SessionToucher interface {
+	TouchSession(sessionID string, activity SessionActivity) error
+}
+
+// touchSession records activity through SessionToucher, falling back to
+// updating the whole session.
+func touchSession(store SessionStore, sessionID string, activity SessionActivity) error {
+	if toucher, ok := store.(SessionToucher); ok {
+		return toucher.TouchSession(sessionID, activity)
+	}
+
+	session, err := store.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+	session.LastSeenAt = activity.SeenAt
+	if activity.IP != "" {
+		session.LastIP = activity.IP
+	}
+	return updateSession(store, session)
+}
+
+// SessionEvictor is implemented by stores that revoke older sessions of a
+// user to enforce a SessionLimit.
+type SessionEvictor interface {
+	// CreateSessionEvicting creates a session like CreateSession and also
+	// returns the sessions it revoked to make room for it.
+	CreateSessionEvicting(userID string, duration time.Duration) (*SessionData, []*SessionData, error)
+}
+
+// createSessionEvicting creates a session, reporting the sessions revoked
+// for it when store implements SessionEvictor.
+func createSessionEvicting(store SessionStore, userID string, duration time.Duration) (*SessionData, []*SessionData, error) {
+	if evictor, ok := store.(SessionEvictor); ok {
+		return evictor.CreateSessionEvicting(userID, duration)
+	}
+	session, err := store.CreateSession(userID, duration)
+	return session, nil, err
+}
+
+// ExpiredSessionPurger is implemented by stores that can report the sessions
+// removed by a cleanup.
+type ExpiredSessionPurger interface {
+	PurgeExpiredSessions() ([]*SessionData, error)
+}
+
+// ActiveSessionCounter is implemented by stores that can count their
+// unexpired sessions.
+type ActiveSessionCounter interface {
+	CountActiveSessions() (int, error)
+}
+
+// SessionQuery selects the sessions returned by SessionLister.ListSessions.
+type SessionQuery struct {
+	// UserID restricts the result to the sessions of one user. Empty
+	// matches every user.
+	UserID string
+
+	// After is the ID of the last session of the previous page. Sessions are
+	// returned ordered by ID, so passing it continues where that page ended.
+	After string
+
+	// Limit caps the number of sessions returned. Zero means no limit.
+	Limit int
+
+	// IncludeExpired also returns expired sessions that were not cleaned up yet.
+	IncludeExpired bool
+}
+
+// SessionLister is implemented by stores that can enumerate their sessions.
+type SessionLister interface {
+	ListSessions(query SessionQuery) ([]*SessionData, error)
+}
+
+// listSessions lists the sessions of stores implementing SessionLister.
+func listSessions(store SessionStore, query SessionQuery) ([]*SessionData, error) {
+	if lister, ok := store.(SessionLister); ok {
+		return lister.ListSessions(query)
+	}
+	return nil, errors.New("store cannot list sessions")
+}
+
+// SessionImporter is implemented by stores that can take over sessions
+// created elsewhere, keeping their IDs.
+type SessionImporter interface {
+	// ImportSession stores session as it is, replacing any session with the
+	// same ID. Session limits are not enforced.
+	ImportSession(session *SessionData) error
+}
+
+// importSession imports into stores implementing SessionImporter.
+func importSession(store SessionStore, session *SessionData) error {
+	if importer, ok := store.(SessionImporter); ok {
+		return importer.ImportSession(session)
+	}
+	return errors.New("store cannot import sessions")
+}
+
+// countActiveSessions counts the sessions of stores implementing ActiveSessionCounter.
+func countActiveSessions(store SessionStore) (int, error) {
+	if counter, ok := store.(ActiveSessionCounter); ok {
+		return counter.CountActiveSessions()
+	}
+	return 0, errors.New("store cannot count sessions")
+}
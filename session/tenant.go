@@ -0,0 +1,124 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const unknownTenantMessage = "Unknown tenant"
+
+// errTenantMismatch is returned when a session is used outside its tenant.
+var errTenantMismatch = errors.New("session belongs to another tenant")
+
+// TenantScoper is implemented by stores that can provide a view restricted
+// to the sessions of one tenant.
+type TenantScoper interface {
+	ForTenant(tenant string) SessionStore
+}
+
+// tenantScope restricts store operations to one tenant unless unscoped.
+type tenantScope struct {
+	tenant string
+	scoped bool
+}
+
+// allTenants is the scope of the store itself, which sees every session.
+var allTenants = tenantScope{}
+
+// allows reports whether a session is visible in the scope.
+func (t tenantScope) allows(session *SessionData) bool {
+	return !t.scoped || session.Tenant == t.tenant
+}
+
+// where returns an SQL condition restricting a query to the scope, along
+// with its arguments.
+func (t tenantScope) where() (string, []any) {
+	if !t.scoped {
+		return "", nil
+	}
+	return " AND tenant = ?", []any{t.tenant}
+}
+
+// TenantResolver determines the tenant a request is addressed to.
+type TenantResolver interface {
+	ResolveTenant(r *http.Request) (string, bool)
+}
+
+// TenantResolverFunc adapts an ordinary function to the TenantResolver interface.
+type TenantResolverFunc func(r *http.Request) (string, bool)
+
+// ResolveTenant calls f(r).
+func (f TenantResolverFunc) ResolveTenant(r *http.Request) (string, bool) {
+	return f(r)
+}
+
+// HostTenantResolver uses the subdomain of Domain as the tenant, so that
+// "acme.example.com" resolves to "acme" for the domain "example.com".
+type HostTenantResolver struct {
+	Domain string
+}
+
+// ResolveTenant returns the subdomain of the request host.
+func (h HostTenantResolver) ResolveTenant(r *http.Request) (string, bool) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+
+	tenant, ok := strings.CutSuffix(strings.ToLower(host), "."+strings.ToLower(h.Domain))
+	if !ok || tenant == "" || strings.Contains(tenant, ".") {
+		return "", false
+	}
+	return tenant, true
+}
+
+// HeaderTenantResolver reads the tenant from a request header.
+type HeaderTenantResolver struct {
+	Name string
+}
+
+// ResolveTenant returns the value of the configured header.
+func (h HeaderTenantResolver) ResolveTenant(r *http.Request) (string, bool) {
+	tenant := strings.TrimSpace(r.Header.Get(h.Name))
+	return tenant, tenant != ""
+}
+
+// ResolveTenant returns a middleware that attaches the tenant of each request
+// to its context and rejects requests whose tenant cannot be resolved. Placed
+// in front of the session middleware, it scopes every store operation to the
+// tenant and rejects session cookies issued by other tenants.
+func ResolveTenant(resolver TenantResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenant, ok := resolver.ResolveTenant(r)
+			if !ok {
+				http.Error(w, unknownTenantMessage, http.StatusNotFound)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithTenant(r.Context(), tenant)))
+		})
+	}
+}
+
+// WithTenant attaches a tenant to a context
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey, tenant)
+}
+
+// TenantFromContext retrieves the tenant from a context
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(tenantContextKey).(string)
+	return tenant, ok
+}
+
+// checkTenant rejects sessions that do not belong to the tenant of a context.
+func checkTenant(ctx context.Context, sessionData *SessionData) error {
+	if tenant, ok := TenantFromContext(ctx); ok && sessionData.Tenant != tenant {
+		return errTenantMismatch
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTenantResolvers(t *testing.T) {
+	tests := []struct {
+		name         string
+		resolver     TenantResolver
+		host         string
+		header       string
+		expectTenant string
+		expectOK     bool
+	}{
+		{"subdomain", HostTenantResolver{Domain: "example.com"}, "acme.example.com", "", "acme", true},
+		{"subdomain with port", HostTenantResolver{Domain: "example.com"}, "Acme.Example.com:8080", "", "acme", true},
+		{"bare domain", HostTenantResolver{Domain: "example.com"}, "example.com", "", "", false},
+		{"nested subdomain", HostTenantResolver{Domain: "example.com"}, "a.b.example.com", "", "", false},
+		{"other domain", HostTenantResolver{Domain: "example.com"}, "acme.example.org", "", "", false},
+		{"header", HeaderTenantResolver{Name: "X-Tenant"}, "example.com", "globex", "globex", true},
+		{"missing header", HeaderTenantResolver{Name: "X-Tenant"}, "example.com", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tt.host
+			if tt.header != "" {
+				req.Header.Set("X-Tenant", tt.header)
+			}
+
+			tenant, ok := tt.resolver.ResolveTenant(req)
+			if tenant != tt.expectTenant || ok != tt.expectOK {
+				t.Errorf("ResolveTenant() = %q, %v, want %q, %v", tenant, ok, tt.expectTenant, tt.expectOK)
+			}
+		})
+	}
+}
+
+func TestResolveTenant(t *testing.T) {
+	handler := ResolveTenant(HostTenantResolver{Domain: "example.com"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, _ := TenantFromContext(r.Context())
+		w.Write([]byte(tenant))
+	}))
+
+	tests := []struct {
+		name       string
+		host       string
+		expectCode int
+		expectBody string
+	}{
+		{"known tenant", "acme.example.com", http.StatusOK, "acme"},
+		{"unresolved tenant", "example.com", http.StatusNotFound, unknownTenantMessage + "\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tt.host
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectCode {
+				t.Errorf("expected code %d, got %d", tt.expectCode, rr.Code)
+			}
+			if rr.Body.String() != tt.expectBody {
+				t.Errorf("expected body %q, got %q", tt.expectBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestForTenant_Isolation(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store SessionStore) {
+		acme := store.(TenantScoper).ForTenant("acme")
+		globex := store.(TenantScoper).ForTenant("globex")
+
+		created, err := acme.CreateSession("user1", time.Hour)
+		if err != nil {
+			t.Fatalf("CreateSession() error = %v", err)
+		}
+		if created.Tenant != "acme" {
+			t.Errorf("expected tenant acme, got %q", created.Tenant)
+		}
+
+		if _, err := globex.GetSession(created.ID); err == nil {
+			t.Error("expected session to be hidden from another tenant")
+		}
+		if err := updateSession(globex, created); err == nil {
+			t.Error("expected update from another tenant to fail")
+		}
+		if err := globex.DeleteSession(created.ID); err != nil {
+			t.Fatalf("DeleteSession() error = %v", err)
+		}
+
+		found, err := acme.GetSession(created.ID)
+		if err != nil {
+			t.Fatalf("expected session to survive deletion by another tenant, got %v", err)
+		}
+		if found.Tenant != "acme" {
+			t.Errorf("expected tenant acme, got %q", found.Tenant)
+		}
+
+		// The unscoped store still sees every tenant.
+		if _, err := store.GetSession(created.ID); err != nil {
+			t.Errorf("expected unscoped store to find session, got %v", err)
+		}
+	})
+}
+
+func TestForTenant_Import(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store SessionStore) {
+		acme := store.(TenantScoper).ForTenant("acme")
+		globex := store.(TenantScoper).ForTenant("globex")
+
+		// The tenant of the view wins over the tenant recorded in the session.
+		imported := &SessionData{ID: generateSessionID(), UserID: "user1", Tenant: "globex", ExpiresAt: time.Now().Add(time.Hour)}
+		if err := importSession(acme, imported); err != nil {
+			t.Fatalf("ImportSession() error = %v", err)
+		}
+		found, err := acme.GetSession(imported.ID)
+		if err != nil {
+			t.Fatalf("GetSession() error = %v", err)
+		}
+		if found.Tenant != "acme" {
+			t.Errorf("expected imported session in tenant acme, got %q", found.Tenant)
+		}
+
+		// Sessions of another tenant are not replaced.
+		if err := importSession(globex, imported); err == nil {
+			t.Error("expected import over a session of another tenant to fail")
+		}
+		if _, err := acme.GetSession(imported.ID); err != nil {
+			t.Errorf("expected the acme session to survive, got %v", err)
+		}
+	})
+}
+
+func TestSession_RejectsCrossTenantCookie(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store SessionStore) {
+		s := &Session{Store: store}
+		handler := ResolveTenant(HostTenantResolver{Domain: "example.com"})(s.ValidateSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})))
+
+		login := httptest.NewRequest(http.MethodPost, "/login", nil)
+		sessionData, err := s.Start(httptest.NewRecorder(), login.WithContext(WithTenant(login.Context(), "acme")), "user1", StartOptions{})
+		if err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+
+		tests := []struct {
+			host       string
+			expectCode int
+		}{
+			{"acme.example.com", http.StatusOK},
+			{"globex.example.com", http.StatusUnauthorized},
+		}
+
+		for _, tt := range tests {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tt.host
+			req.AddCookie(&http.Cookie{Name: CookieName, Value: sessionData.ID})
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.expectCode {
+				t.Errorf("%s: expected code %d, got %d", tt.host, tt.expectCode, rr.Code)
+			}
+		}
+	})
+}
+
+func TestSession_RememberMeStaysInTenant(t *testing.T) {
+	store := NewInMemorySessionStore()
+	s := &Session{Store: store, RememberMe: &RememberMe{Store: store}}
+
+	login := httptest.NewRequest(http.MethodPost, "/login", nil)
+	rr := httptest.NewRecorder()
+	if _, err := s.Start(rr, login.WithContext(WithTenant(login.Context(), "acme")), "user1", StartOptions{Remember: true}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(WithTenant(req.Context(), "globex"))
+	req.AddCookie(rememberCookie(rr))
+
+	if _, err := s.loadSession(httptest.NewRecorder(), req); err == nil {
+		t.Error("expected remember-me token of another tenant to be rejected")
+	}
+
+	// The rejection neither rotated nor revoked the token.
+	acme := tenantScope{tenant: "acme", scoped: true}
+	if _, _, err := s.RememberMe.consume(acme, rememberCookie(rr).Value); err != nil {
+		t.Errorf("expected the token to keep working in its tenant, got %v", err)
+	}
+}
+
+func TestStores_DeleteUserRememberTokensStaysInTenant(t *testing.T) {
+	forEachStore(t, func(t *testing.T, store SessionStore) {
+		tokens := store.(RememberStore)
+		for _, tenant := range []string{"acme", "globex"} {
+			_ = tokens.SaveRememberToken(&RememberToken{
+				Selector:      tenant,
+				ValidatorHash: sha256Hex("validator"),
+				UserID:        "user1",
+				Tenant:        tenant,
+				ExpiresAt:     time.Now().Add(time.Hour),
+			})
+		}
+
+		if err := tokens.DeleteUserRememberTokens("acme", "user1"); err != nil {
+			t.Fatalf("DeleteUserRememberTokens() error = %v", err)
+		}
+		if _, err := tokens.GetRememberToken("acme"); err == nil {
+			t.Error("expected the acme token to be deleted")
+		}
+		if _, err := tokens.GetRememberToken("globex"); err != nil {
+			t.Errorf("expected the globex token of the same user to survive, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,242 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Span names used by the session middleware and store wrappers.
+const (
+	spanValidate = "session.validate"
+	spanStore    = "session.store."
+)
+
+// Attribute keys set on session spans.
+const (
+	AttrOperation = "session.operation"
+	AttrHit       = "session.hit"
+	AttrErrorKind = "session.error_kind"
+	AttrTenant    = "session.tenant"
+)
+
+// Tracer starts spans around session operations. It mirrors the subset of
+// the OpenTelemetry tracing API the package needs, so that any tracing
+// backend can be plugged in through a small adapter.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced operation started by a Tracer.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	// RecordError marks the span as failed.
+	RecordError(err error)
+	End()
+}
+
+// Attribute is a key-value pair describing a span. Values are strings,
+// bools or ints.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// errorKind classifies an error for the AttrErrorKind attribute.
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, ErrSessionNotFound):
+		return "not_found"
+	case errors.Is(err, ErrSessionExpired):
+		return "expired"
+	case errors.Is(err, ErrSessionLimitReached):
+		return "limit_reached"
+	default:
+		return "backend"
+	}
+}
+
+// endSpan records the outcome of an operation on its span and ends it.
+// Lookups of unknown or expired sessions are misses, not failures.
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.SetAttributes(Attribute{AttrErrorKind, errorKind(err)})
+		if !errors.Is(err, ErrSessionNotFound) && !errors.Is(err, ErrSessionExpired) {
+			span.RecordError(err)
+		}
+	}
+	span.End()
+}
+
+// TraceStore wraps a store so that each operation runs in its own span. The
+// Session traces its store calls itself when its Tracer is set, with spans
+// parented to the request; TraceStore is meant for code using a store
+// directly.
+func TraceStore(store SessionStore, tracer Tracer) SessionStore {
+	return &tracedStore{store: store, tracer: tracer, ctx: context.Background()}
+}
+
+// tracedStore starts store spans as children of the span in ctx.
+type tracedStore struct {
+	store  SessionStore
+	tracer Tracer
+	ctx    context.Context
+}
+
+func (s *tracedStore) start(operation string) Span {
+	_, span := s.tracer.Start(s.ctx, spanStore+operation)
+	span.SetAttributes(Attribute{AttrOperation, operation})
+	if tenant, ok := TenantFromContext(s.ctx); ok {
+		span.SetAttributes(Attribute{AttrTenant, tenant})
+	}
+	return span
+}
+
+func (s *tracedStore) CreateSession(userID string, duration time.Duration) (*SessionData, error) {
+	session, _, err := s.CreateSessionEvicting(userID, duration)
+	return session, err
+}
+
+func (s *tracedStore) CreateSessionEvicting(userID string, duration time.Duration) (*SessionData, []*SessionData, error) {
+	span := s.start("create")
+	session, evicted, err := createSessionEvicting(s.store, userID, duration)
+	endSpan(span, err)
+	return session, evicted, err
+}
+
+func (s *tracedStore) GetSession(sessionID string) (*SessionData, error) {
+	span := s.start("get")
+	session, err := s.store.GetSession(sessionID)
+	span.SetAttributes(Attribute{AttrHit, err == nil})
+	endSpan(span, err)
+	return session, err
+}
+
+func (s *tracedStore) UpdateSession(session *SessionData) error {
+	span := s.start("update")
+	err := updateSession(s.store, session)
+	endSpan(span, err)
+	return err
+}
+
+func (s *tracedStore) TouchSession(sessionID string, activity SessionActivity) error {
+	span := s.start("touch")
+	err := touchSession(s.store, sessionID, activity)
+	endSpan(span, err)
+	return err
+}
+
+func (s *tracedStore) DeleteSession(sessionID string) error {
+	span := s.start("delete")
+	err := s.store.DeleteSession(sessionID)
+	endSpan(span, err)
+	return err
+}
+
+func (s *tracedStore) CleanupExpiredSessions() error {
+	span := s.start("cleanup")
+	err := s.store.CleanupExpiredSessions()
+	endSpan(span, err)
+	return err
+}
+
+// PurgeExpiredSessions traces a cleanup, purging through the wrapped store
+// when it implements ExpiredSessionPurger.
+func (s *tracedStore) PurgeExpiredSessions() ([]*SessionData, error) {
+	span := s.start("cleanup")
+	var purged []*SessionData
+	var err error
+	if purger, ok := s.store.(ExpiredSessionPurger); ok {
+		purged, err = purger.PurgeExpiredSessions()
+	} else {
+		err = s.store.CleanupExpiredSessions()
+	}
+	endSpan(span, err)
+	return purged, err
+}
+
+func (s *tracedStore) CountActiveSessions() (int, error) {
+	span := s.start("count")
+	count, err := countActiveSessions(s.store)
+	endSpan(span, err)
+	return count, err
+}
+
+func (s *tracedStore) ListSessions(query SessionQuery) ([]*SessionData, error) {
+	span := s.start("list")
+	sessions, err := listSessions(s.store, query)
+	endSpan(span, err)
+	return sessions, err
+}
+
+func (s *tracedStore) ImportSession(session *SessionData) error {
+	span := s.start("import")
+	err := importSession(s.store, session)
+	endSpan(span, err)
+	return err
+}
+
+// ForTenant traces the tenant view of the wrapped store.
+func (s *tracedStore) ForTenant(tenant string) SessionStore {
+	if scoper, ok := s.store.(TenantScoper); ok {
+		return &tracedStore{store: scoper.ForTenant(tenant), tracer: s.tracer, ctx: s.ctx}
+	}
+	return s
+}
+
+// SpanRecorder is a Tracer that keeps finished spans in memory, for tests.
+type SpanRecorder struct {
+	mutex sync.Mutex
+	spans []RecordedSpan
+}
+
+// RecordedSpan is a span finished by a SpanRecorder.
+type RecordedSpan struct {
+	Name       string
+	Parent     string
+	Attributes map[string]any
+	Err        error
+}
+
+// spanRecorderKey holds the name of the current recorded span in a context.
+type spanRecorderKey struct{}
+
+// Start begins a span whose parent is the recorded span of ctx, if any.
+func (r *SpanRecorder) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(spanRecorderKey{}).(string)
+	span := &recordedSpan{
+		recorder: r,
+		span:     RecordedSpan{Name: name, Parent: parent, Attributes: make(map[string]any)},
+	}
+	return context.WithValue(ctx, spanRecorderKey{}, name), span
+}
+
+// Spans returns the finished spans in the order they ended.
+func (r *SpanRecorder) Spans() []RecordedSpan {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]RecordedSpan(nil), r.spans...)
+}
+
+// recordedSpan is the Span returned by SpanRecorder.Start.
+type recordedSpan struct {
+	recorder *SpanRecorder
+	span     RecordedSpan
+}
+
+func (s *recordedSpan) SetAttributes(attrs ...Attribute) {
+	for _, attr := range attrs {
+		s.span.Attributes[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordedSpan) RecordError(err error) {
+	s.span.Err = err
+}
+
+func (s *recordedSpan) End() {
+	s.recorder.mutex.Lock()
+	defer s.recorder.mutex.Unlock()
+	s.recorder.spans = append(s.recorder.spans, s.span)
+}
@@ -0,0 +1,99 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSession_Tracing(t *testing.T) {
+	store := NewInMemorySessionStore()
+	created, _ := store.CreateSession("user1", time.Hour)
+
+	tests := []struct {
+		name          string
+		token         string
+		expectHit     bool
+		expectErrKind any
+	}{
+		{"valid session", created.ID, true, nil},
+		{"unknown session", "unknown", false, reasonNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &SpanRecorder{}
+			s := &Session{Store: store, Tracer: recorder, TouchInterval: -1}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.AddCookie(&http.Cookie{Name: CookieName, Value: tt.token})
+			s.ValidateSession(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := recorder.Spans()
+			if len(spans) != 2 {
+				t.Fatalf("expected 2 spans, got %v", spans)
+			}
+
+			get, validate := spans[0], spans[1]
+			if get.Name != "session.store.get" || get.Parent != spanValidate {
+				t.Errorf("expected store span under %q, got %q under %q", spanValidate, get.Name, get.Parent)
+			}
+			if get.Attributes[AttrHit] != tt.expectHit {
+				t.Errorf("expected store hit %v, got %v", tt.expectHit, get.Attributes[AttrHit])
+			}
+			if get.Err != nil {
+				t.Errorf("expected a miss not to be recorded as an error, got %v", get.Err)
+			}
+			if validate.Name != spanValidate || validate.Attributes[AttrHit] != tt.expectHit {
+				t.Errorf("unexpected validation span %+v", validate)
+			}
+			if validate.Attributes[AttrErrorKind] != tt.expectErrKind {
+				t.Errorf("expected error kind %v, got %v", tt.expectErrKind, validate.Attributes[AttrErrorKind])
+			}
+		})
+	}
+}
+
+func TestTraceStore_BackendError(t *testing.T) {
+	recorder := &SpanRecorder{}
+	failure := errors.New("database is locked")
+	store := TraceStore(&MockSessionStore{DeleteSessionFunc: func(string) error { return failure }}, recorder)
+
+	if err := store.DeleteSession("id"); !errors.Is(err, failure) {
+		t.Fatalf("DeleteSession() error = %v, want %v", err, failure)
+	}
+
+	spans := recorder.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Err != failure || spans[0].Attributes[AttrErrorKind] != "backend" {
+		t.Errorf("expected backend failure on span, got %+v", spans[0])
+	}
+	if spans[0].Attributes[AttrOperation] != "delete" {
+		t.Errorf("expected delete operation, got %v", spans[0].Attributes[AttrOperation])
+	}
+}
+
+func TestTraceStore_Passthroughs(t *testing.T) {
+	recorder := &SpanRecorder{}
+	backing := NewInMemorySessionStore()
+	_, _ = backing.CreateSession("user1", time.Hour)
+	expired, _ := backing.CreateSession("user2", -time.Minute)
+	store := TraceStore(backing, recorder)
+
+	purged, err := store.(ExpiredSessionPurger).PurgeExpiredSessions()
+	if err != nil || len(purged) != 1 || purged[0].ID != expired.ID {
+		t.Errorf("PurgeExpiredSessions() = %v, %v; want the expired session", purged, err)
+	}
+	if count, err := countActiveSessions(store); err != nil || count != 1 {
+		t.Errorf("CountActiveSessions() = %d, %v; want 1", count, err)
+	}
+
+	spans := recorder.Spans()
+	if len(spans) != 2 || spans[0].Name != spanStore+"cleanup" || spans[1].Name != spanStore+"count" {
+		t.Errorf("expected cleanup and count spans, got %+v", spans)
+	}
+}